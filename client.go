@@ -3,12 +3,16 @@ package marvin
 import (
 	"bytes"
 	"context"
-	"errors"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
+	"github.com/go-kit/kit/endpoint"
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
 )
 
 // EncodeProtoRequest is an httptransport.EncodeRequestFunc that serializes the request
@@ -34,3 +38,272 @@ func EncodeProtoRequest(_ context.Context, r *http.Request, preq interface{}) er
 	r.Body = ioutil.NopCloser(bytes.NewReader(b))
 	return nil
 }
+
+// ResponseError is returned by DecodeProtoResponse and DecodeJSONResponse when
+// a service responds with a non-2xx status code. It implements
+// httptransport.StatusCoder so callers can inspect the status code without
+// parsing the error message.
+type ResponseError struct {
+	// Code is the HTTP status code of the response.
+	Code int
+	// Payload is the decoded error body or nil if no error type was
+	// provided for the status code or the body could not be decoded.
+	Payload interface{}
+	// Body is the raw response body.
+	Body []byte
+}
+
+// StatusCode is to implement httptransport.StatusCoder
+func (e *ResponseError) StatusCode() int {
+	return e.Code
+}
+
+// Error is to implement error. If the Payload has a GetMessage() method, like
+// ErrorMessage, its message will be included.
+func (e *ResponseError) Error() string {
+	msg := http.StatusText(e.Code)
+	if m, ok := e.Payload.(interface {
+		GetMessage() string
+	}); ok && m.GetMessage() != "" {
+		msg = m.GetMessage()
+	}
+	return "status " + strconv.Itoa(e.Code) + ": " + msg
+}
+
+// DecodeProtoResponse returns an httptransport.DecodeResponseFunc that will
+// unmarshal 2xx Protobuf responses into a message from the newRes factory.
+//
+// For all other status codes, a *ResponseError will be returned and the body
+// will be unmarshaled into the message newErr returns for the status code. This
+// allows for different error types per status range:
+//
+//	marvin.DecodeProtoResponse(
+//		func() proto.Message { return &Links{} },
+//		func(code int) proto.Message {
+//			if code >= 500 {
+//				return &ServerError{}
+//			}
+//			return &Message{}
+//		},
+//	)
+//
+// If newErr is nil or returns nil, the error body will not be decoded.
+func DecodeProtoResponse(newRes ProtoFactory, newErr func(code int) proto.Message) httptransport.DecodeResponseFunc {
+	return func(_ context.Context, r *http.Response) (interface{}, error) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read response")
+		}
+		if !isSuccess(r.StatusCode) {
+			rerr := &ResponseError{Code: r.StatusCode, Body: b}
+			if newErr == nil {
+				return nil, rerr
+			}
+			if msg := newErr(r.StatusCode); msg != nil && proto.Unmarshal(b, msg) == nil {
+				rerr.Payload = msg
+			}
+			return nil, rerr
+		}
+		res := newRes()
+		if err = proto.Unmarshal(b, res); err != nil {
+			return nil, errors.Wrap(err, "unable to parse response")
+		}
+		return res, nil
+	}
+}
+
+// DecodeJSONResponse returns an httptransport.DecodeResponseFunc that will
+// unmarshal 2xx JSON responses into a value from the newRes factory.
+//
+// For all other status codes, a *ResponseError will be returned and the body
+// will be unmarshaled into the value newErr returns for the status code.
+// If newErr is nil or returns nil, the error body will not be decoded.
+func DecodeJSONResponse(newRes JSONFactory, newErr func(code int) interface{}) httptransport.DecodeResponseFunc {
+	return func(_ context.Context, r *http.Response) (interface{}, error) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read response")
+		}
+		if !isSuccess(r.StatusCode) {
+			rerr := &ResponseError{Code: r.StatusCode, Body: b}
+			if newErr == nil {
+				return nil, rerr
+			}
+			if v := newErr(r.StatusCode); v != nil && DefaultJSONCodec.Unmarshal(b, v) == nil {
+				rerr.Payload = v
+			}
+			return nil, rerr
+		}
+		res := newRes()
+		if len(b) == 0 {
+			return res, nil
+		}
+		if err = DefaultJSONCodec.Unmarshal(b, res); err != nil {
+			return nil, errors.Wrap(err, "unable to parse response")
+		}
+		return res, nil
+	}
+}
+
+func isSuccess(code int) bool {
+	return code >= 200 && code < 300
+}
+
+// Varser can be implemented by client requests to supply the values for the
+// variables in a route's path, like the `{id}` in "/cat/{id}.json".
+type Varser interface {
+	Vars() map[string]string
+}
+
+// ClientEndpoint holds everything required to build a go-kit client for one of a
+// service's endpoints.
+type ClientEndpoint struct {
+	// Encoder defaults to EncodeJSONRequest for JSON endpoints and
+	// EncodeProtoRequest for Protobuf and negotiated endpoints.
+	Encoder httptransport.EncodeRequestFunc
+	// Decoder is required. See DecodeProtoResponse and DecodeJSONResponse.
+	Decoder httptransport.DecodeResponseFunc
+	Options []httptransport.ClientOption
+	// Version selects the version of a Group endpoint to call and is sent in the
+	// 'Accept-Version' header. If it is empty, the client calls the version the
+	// server would use by default: the endpoint without a version or the latest.
+	Version string
+}
+
+// NewClient will build go-kit client endpoints for a service's endpoint maps. The
+// svc argument must implement JSONEndpointer, ProtoEndpointer or NegotiatedEndpointer
+// and the clients map uses the same path and method keys as the service's endpoint
+// maps. Only routes found in the clients map will be included in the result and the
+// given options will be applied to every client.
+//
+// A path and method that is served by several versions of a Group is called with
+// the 'Accept-Version' header of the ClientEndpoint's Version. An error is returned
+// if a requested path and method is registered more than once for a version.
+//
+// If a request implements Varser, its values will be substituted into the route's
+// path variables before the request is sent:
+//
+//	eps, err := marvin.NewClient("https://my-app.appspot.com", svc,
+//		map[string]map[string]marvin.ClientEndpoint{
+//			"/cat/{id}.json": {
+//				"GET": {Decoder: decodeCatResponse},
+//			},
+//		})
+//	cat, err := eps["/cat/{id}.json"]["GET"](ctx, getCatRequest{ID: "1"})
+func NewClient(baseURL string, svc interface{}, clients map[string]map[string]ClientEndpoint,
+	opts ...httptransport.ClientOption) (map[string]map[string]endpoint.Endpoint, error) {
+	sets := endpointSets(svc)
+	if len(sets) == 0 {
+		return nil, errors.New("clients can only be built for services that implement one of the Endpointer interfaces")
+	}
+	base, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid base url")
+	}
+
+	// find every version of the requested routes
+	found := map[string]map[string][]endpointSet{}
+	for _, set := range sets {
+		for path, epMethods := range set.endpoints {
+			for method := range epMethods {
+				if _, ok := clients[path][method]; !ok {
+					continue
+				}
+				if found[path] == nil {
+					found[path] = map[string][]endpointSet{}
+				}
+				for _, prev := range found[path][method] {
+					if prev.version == set.version {
+						return nil, errors.Errorf("%s %s is registered more than once", method, path)
+					}
+				}
+				found[path][method] = append(found[path][method], set)
+			}
+		}
+	}
+
+	out := map[string]map[string]endpoint.Endpoint{}
+	for path, methods := range clients {
+		for method, ce := range methods {
+			set, ok := clientSet(found[path][method], ce.Version)
+			if !ok {
+				if ce.Version != "" {
+					return nil, errors.Errorf("service has no endpoint for %s %s version %s", method, path, ce.Version)
+				}
+				return nil, errors.Errorf("service has no endpoint for %s %s", method, path)
+			}
+			if ce.Decoder == nil {
+				return nil, errors.Errorf("no response decoder provided for %s %s", method, path)
+			}
+			tgt, err := url.Parse(base.String() + path)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid url for %s %s", method, path)
+			}
+			if out[path] == nil {
+				out[path] = map[string]endpoint.Endpoint{}
+			}
+			out[path][method] = httptransport.NewClient(
+				method,
+				tgt,
+				clientEncoder(base, path, set, ce.Encoder),
+				ce.Decoder,
+				append(opts, ce.Options...)...,
+			).Endpoint()
+		}
+	}
+	return out, nil
+}
+
+// clientSet will pick the endpoint set of the given version. Without a version,
+// it picks the set the server uses by default: no version, then the latest.
+func clientSet(sets []endpointSet, version string) (endpointSet, bool) {
+	var (
+		best  endpointSet
+		found bool
+	)
+	for _, set := range sets {
+		switch {
+		case version != "":
+			if set.version == version {
+				return set, true
+			}
+		case !found, set.version == "",
+			best.version != "" && compareVersions(set.version, best.version) > 0:
+			best, found = set, true
+		}
+	}
+	return best, found
+}
+
+// clientEncoder will wrap the given request encoder to fill in any path
+// variables and set the 'Accept' and 'Accept-Version' headers for the endpoint's
+// format and version.
+func clientEncoder(base *url.URL, path string, set endpointSet, enc httptransport.EncodeRequestFunc) httptransport.EncodeRequestFunc {
+	f := set.format
+	accept := "application/json"
+	if f != FormatJSON {
+		accept = "application/x-protobuf"
+	}
+	if enc == nil {
+		enc = EncodeJSONRequest
+		if f != FormatJSON {
+			enc = EncodeProtoRequest
+		}
+	}
+	return func(ctx context.Context, r *http.Request, req interface{}) error {
+		var vars map[string]string
+		if v, ok := req.(Varser); ok {
+			vars = v.Vars()
+		}
+		p, raw, err := expandPath(path, vars)
+		if err != nil {
+			return err
+		}
+		r.URL.Path, r.URL.RawPath = base.Path+p, base.EscapedPath()+raw
+		r.Header.Set("Accept", accept)
+		if set.version != "" {
+			r.Header.Set("Accept-Version", set.version)
+		}
+		return enc(ctx, r, req)
+	}
+}
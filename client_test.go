@@ -0,0 +1,164 @@
+package marvin
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+)
+
+func TestDecodeResponse(t *testing.T) {
+	protoMsg := func(msg string) string {
+		b, _ := proto.Marshal(&ErrorMessage{Message: msg})
+		return string(b)
+	}
+	decoders := map[Format]func(newErr bool) func(context.Context, *http.Response) (interface{}, error){
+		FormatJSON: func(newErr bool) func(context.Context, *http.Response) (interface{}, error) {
+			var errFactory func(int) interface{}
+			if newErr {
+				errFactory = func(int) interface{} { return &ErrorMessage{} }
+			}
+			return DecodeJSONResponse(func() interface{} { return &ErrorMessage{} }, errFactory)
+		},
+		FormatProto: func(newErr bool) func(context.Context, *http.Response) (interface{}, error) {
+			var errFactory func(int) proto.Message
+			if newErr {
+				errFactory = func(int) proto.Message { return &ErrorMessage{} }
+			}
+			return DecodeProtoResponse(func() proto.Message { return &ErrorMessage{} }, errFactory)
+		},
+	}
+
+	tests := []struct {
+		name   string
+		format Format
+		code   int
+		body   string
+		newErr bool
+
+		wantMessage string
+		wantErr     string
+		wantPayload bool
+	}{
+		{name: "json", format: FormatJSON, code: http.StatusOK, body: `{"message":"hi"}`, wantMessage: "hi"},
+		{name: "json empty", format: FormatJSON, code: http.StatusNoContent},
+		{name: "json error", format: FormatJSON, code: http.StatusNotFound, body: `{"message":"no cat"}`, newErr: true, wantErr: "status 404: no cat", wantPayload: true},
+		{name: "json error without factory", format: FormatJSON, code: http.StatusNotFound, body: `{"message":"no cat"}`, wantErr: "status 404: Not Found"},
+		{name: "json malformed", format: FormatJSON, code: http.StatusOK, body: `{"message":`, wantErr: "unable to parse response"},
+		{name: "proto", format: FormatProto, code: http.StatusOK, body: protoMsg("hi"), wantMessage: "hi"},
+		{name: "proto error", format: FormatProto, code: http.StatusInternalServerError, body: protoMsg("oops"), newErr: true, wantErr: "status 500: oops", wantPayload: true},
+		{name: "proto malformed error", format: FormatProto, code: http.StatusBadGateway, body: "\xff\xff", newErr: true, wantErr: "status 502: Bad Gateway"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := decoders[test.format](test.newErr)(context.Background(), &http.Response{
+				StatusCode: test.code,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(test.body)),
+			})
+			if test.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), test.wantErr) {
+					t.Fatalf("expected error %q, got %v", test.wantErr, err)
+				}
+				if rerr, ok := err.(*ResponseError); ok {
+					if rerr.StatusCode() != test.code || string(rerr.Body) != test.body {
+						t.Errorf("expected the error to hold the response, got %d %q", rerr.StatusCode(), rerr.Body)
+					}
+					if (rerr.Payload != nil) != test.wantPayload {
+						t.Errorf("expected a payload: %t, got %v", test.wantPayload, rerr.Payload)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+			if got := res.(*ErrorMessage).Message; got != test.wantMessage {
+				t.Errorf("expected message %q, got %q", test.wantMessage, got)
+			}
+		})
+	}
+}
+
+type catRequest struct {
+	ID string `json:"-"`
+}
+
+func (r catRequest) Vars() map[string]string { return map[string]string{"id": r.ID} }
+
+func TestNewClient(t *testing.T) {
+	svc := groupService{
+		testService: testService{endpoints: map[string]map[string]HTTPEndpoint{
+			"/cat/{id}": {"GET": {
+				Endpoint: func(_ context.Context, req interface{}) (interface{}, error) {
+					return "cat " + Vars(req.(*http.Request))["id"], nil
+				},
+			}},
+		}},
+		groups: []Group{
+			{Prefix: "/api", Version: "v1", JSONEndpoints: map[string]map[string]HTTPEndpoint{
+				"/dog": {"GET": testEndpoint("v1")},
+			}},
+			{Prefix: "/api", Version: "v2", JSONEndpoints: map[string]map[string]HTTPEndpoint{
+				"/dog": {"GET": testEndpoint("v2")},
+			}},
+		},
+	}
+	hs := httptest.NewServer(newTestServer(t, svc))
+	defer hs.Close()
+	dec := DecodeJSONResponse(func() interface{} { return new(string) }, nil)
+
+	tests := []struct {
+		name    string
+		svc     interface{}
+		path    string
+		client  ClientEndpoint
+		request interface{}
+
+		want    string
+		wantErr string
+	}{
+		{name: "path variables", svc: svc, path: "/cat/{id}", client: ClientEndpoint{Decoder: dec}, request: catRequest{ID: "1"}, want: "cat 1"},
+		{name: "default version", svc: svc, path: "/api/dog", client: ClientEndpoint{Decoder: dec}, want: "v2"},
+		{name: "version", svc: svc, path: "/api/dog", client: ClientEndpoint{Decoder: dec, Version: "v1"}, want: "v1"},
+		{name: "unknown version", svc: svc, path: "/api/dog", client: ClientEndpoint{Decoder: dec, Version: "v3"}, wantErr: "service has no endpoint for GET /api/dog version v3"},
+		{name: "unknown path", svc: svc, path: "/bird", client: ClientEndpoint{Decoder: dec}, wantErr: "service has no endpoint for GET /bird"},
+		{name: "no decoder", svc: svc, path: "/api/dog", wantErr: "no response decoder provided for GET /api/dog"},
+		{
+			name: "duplicate",
+			svc: groupService{testService: svc.testService, groups: []Group{
+				{JSONEndpoints: svc.testService.endpoints},
+			}},
+			path:    "/cat/{id}",
+			client:  ClientEndpoint{Decoder: dec},
+			wantErr: "GET /cat/{id} is registered more than once",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			eps, err := NewClient(hs.URL, test.svc, map[string]map[string]ClientEndpoint{
+				test.path: {"GET": test.client},
+			})
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Fatalf("expected error %q, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unable to create client: %s", err)
+			}
+			res, err := eps[test.path]["GET"](context.Background(), test.request)
+			if err != nil {
+				t.Fatalf("unable to call endpoint: %s", err)
+			}
+			if got := *res.(*string); got != test.want {
+				t.Errorf("expected response %q, got %q", test.want, got)
+			}
+		})
+	}
+}
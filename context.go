@@ -0,0 +1,55 @@
+package marvin
+
+import (
+	"context"
+	stdlog "log"
+	"net/http"
+
+	"google.golang.org/appengine/log"
+
+	"github.com/NYTimes/marvin/internal"
+)
+
+// ContextProvider builds the base context for each request a Server receives.
+type ContextProvider func(r *http.Request) context.Context
+
+// BaseContext is a server option to set the ContextProvider for the requests of a
+// single Server. Servers without one use AppEngineContext, or the context set by
+// marvintest.SetServerContext.
+func BaseContext(p ContextProvider) ServerOption {
+	return func(s *Server) {
+		s.newContext = p
+	}
+}
+
+// baseContext returns the base context for a request.
+func (s Server) baseContext(r *http.Request) context.Context {
+	if s.newContext != nil {
+		return s.newContext(r)
+	}
+	return internal.NewContext(r)
+}
+
+// AppEngineContext is the default ContextProvider. It returns the App Engine
+// context for the request, so it can only be used for services started with Init
+// on App Engine standard or after appengine.Main.
+func AppEngineContext(r *http.Request) context.Context {
+	return internal.AppEngineContext(r)
+}
+
+// StandardContext is a ContextProvider that returns the request's own context. It
+// does not need the App Engine API, so services using it can run on any runtime,
+// Cloud Run or a laptop. Run uses it by default.
+func StandardContext(r *http.Request) context.Context {
+	return r.Context()
+}
+
+// logErrorf will log to App Engine when the context came from the AppEngineContext
+// provider and to the standard logger otherwise.
+func logErrorf(ctx context.Context, format string, args ...interface{}) {
+	if internal.IsAppEngine(ctx) {
+		log.Errorf(ctx, format, args...)
+		return
+	}
+	stdlog.Printf("ERROR: "+format, args...)
+}
@@ -0,0 +1,52 @@
+package marvin
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/NYTimes/marvin/internal"
+)
+
+type testContextKey int
+
+// withName returns a ContextProvider that adds the name to the request's context.
+func withName(name string) ContextProvider {
+	return func(r *http.Request) context.Context {
+		return context.WithValue(r.Context(), testContextKey(0), name)
+	}
+}
+
+func TestBaseContext(t *testing.T) {
+	defer func(p func(*http.Request) context.Context) { internal.NewContext = p }(internal.NewContext)
+	internal.NewContext = withName("global")
+
+	tests := []struct {
+		name string
+		opts []ServerOption
+
+		wantName string
+	}{
+		{name: "global", wantName: "global"},
+		{name: "base context", opts: []ServerOption{BaseContext(withName("server"))}, wantName: "server"},
+		{name: "standard context", opts: []ServerOption{BaseContext(StandardContext)}, wantName: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svr, err := NewServerE(testService{endpoints: map[string]map[string]HTTPEndpoint{
+				"/name": {"GET": HTTPEndpoint{Endpoint: func(ctx context.Context, _ interface{}) (interface{}, error) {
+					name, _ := ctx.Value(testContextKey(0)).(string)
+					return name, nil
+				}}},
+			}}, test.opts...)
+			if err != nil {
+				t.Fatalf("unable to create server: %s", err)
+			}
+			w := serve(svr, http.MethodGet, "/name", "")
+			if got := strings.TrimSpace(w.Body.String()); got != `"`+test.wantName+`"` {
+				t.Errorf("expected name %q, got %s", test.wantName, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package marvin
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/golang/protobuf/proto"
+)
+
+// DefaultMaxBodyBytes is the largest request body the marvin decoders will read
+// unless the MaxBodyBytes option is given. It matches the App Engine request size
+// limit.
+const DefaultMaxBodyBytes = 32 << 20
+
+// ProtoFactory returns a new, empty proto.Message to decode a body into.
+type ProtoFactory func() proto.Message
+
+// JSONFactory returns a new pointer value to decode a JSON body into.
+type JSONFactory func() interface{}
+
+// DecoderOption sets optional behavior for the marvin request decoders.
+type DecoderOption func(*decoderConfig)
+
+type decoderConfig struct {
+	maxBytes   int64
+	allowEmpty bool
+}
+
+// MaxBodyBytes sets the largest request body a decoder will accept. Larger
+// bodies will be rejected with a 413 status code.
+func MaxBodyBytes(n int64) DecoderOption {
+	return func(c *decoderConfig) {
+		c.maxBytes = n
+	}
+}
+
+// AllowEmptyBody will make a decoder return the empty message from its factory
+// instead of rejecting requests without a body.
+func AllowEmptyBody() DecoderOption {
+	return func(c *decoderConfig) {
+		c.allowEmpty = true
+	}
+}
+
+// DecodeProtoRequest returns an httptransport.DecodeRequestFunc that will unmarshal
+// the Protobuf request body into a message created by the given factory.
+//
+// Bodies that are empty, too large or malformed will be rejected with a
+// ProtoStatusResponse wrapping an ErrorMessage so it can be serialized in
+// either format.
+func DecodeProtoRequest(newMsg ProtoFactory, opts ...DecoderOption) httptransport.DecodeRequestFunc {
+	cfg := newDecoderConfig(opts)
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		b, err := cfg.readBody(r)
+		if err != nil {
+			return nil, err
+		}
+		msg := newMsg()
+		if len(b) == 0 {
+			return msg, nil
+		}
+		if err = proto.Unmarshal(b, msg); err != nil {
+			return nil, badRequest("unable to parse request: malformed protobuf")
+		}
+		return msg, nil
+	}
+}
+
+// DecodeJSONRequest returns an httptransport.DecodeRequestFunc that will unmarshal
+// the JSON request body into a value created by the given factory.
+//
+// Bodies that are empty, too large or malformed will be rejected with a
+// ProtoStatusResponse wrapping an ErrorMessage so it can be serialized in
+// either format.
+func DecodeJSONRequest(newMsg JSONFactory, opts ...DecoderOption) httptransport.DecodeRequestFunc {
+	cfg := newDecoderConfig(opts)
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		b, err := cfg.readBody(r)
+		if err != nil {
+			return nil, err
+		}
+		msg := newMsg()
+		if len(b) == 0 {
+			return msg, nil
+		}
+		if err = DefaultJSONCodec.Unmarshal(b, msg); err != nil {
+			return nil, badRequest("unable to parse request: malformed JSON")
+		}
+		return msg, nil
+	}
+}
+
+// DecodeRequest returns an httptransport.DecodeRequestFunc that will decode the
+// request body as JSON or Protobuf based on the request's 'Content-Type' header.
+// This is meant to be used with NegotiatedEndpoints.
+func DecodeRequest(newMsg ProtoFactory, opts ...DecoderOption) httptransport.DecodeRequestFunc {
+	return NegotiatedDecoder(
+		DecodeJSONRequest(func() interface{} { return newMsg() }, opts...),
+		DecodeProtoRequest(newMsg, opts...),
+	)
+}
+
+func newDecoderConfig(opts []DecoderOption) *decoderConfig {
+	cfg := &decoderConfig{maxBytes: DefaultMaxBodyBytes}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// readBody will read the request body while enforcing the configured limits.
+func (c *decoderConfig) readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, c.checkEmpty()
+	}
+	if r.ContentLength > c.maxBytes {
+		return nil, tooLarge()
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(r.Body, c.maxBytes+1))
+	if err != nil {
+		return nil, badRequest("unable to read request")
+	}
+	if int64(len(b)) > c.maxBytes {
+		return nil, tooLarge()
+	}
+	if len(b) == 0 {
+		return nil, c.checkEmpty()
+	}
+	return b, nil
+}
+
+func (c *decoderConfig) checkEmpty() error {
+	if c.allowEmpty {
+		return nil
+	}
+	return badRequest("empty request body")
+}
+
+func badRequest(msg string) error {
+	return NewProtoStatusResponse(&ErrorMessage{Message: msg}, http.StatusBadRequest)
+}
+
+func tooLarge() error {
+	return NewProtoStatusResponse(&ErrorMessage{Message: "request body too large"},
+		http.StatusRequestEntityTooLarge)
+}
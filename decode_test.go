@@ -0,0 +1,75 @@
+package marvin
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestDecodeRequest(t *testing.T) {
+	protoBody, _ := proto.Marshal(&ErrorMessage{Message: "hi"})
+	newMsg := func() proto.Message { return &ErrorMessage{} }
+
+	tests := []struct {
+		name          string
+		contentType   string
+		body          string
+		unknownLength bool
+		opts          []DecoderOption
+
+		wantCode    int
+		wantMessage string
+	}{
+		{name: "json", contentType: "application/json", body: `{"message":"hi"}`, wantMessage: "hi"},
+		{name: "proto", contentType: "application/x-protobuf", body: string(protoBody), wantMessage: "hi"},
+		{name: "empty", contentType: "application/json", wantCode: http.StatusBadRequest},
+		{name: "empty allowed", contentType: "application/json", opts: []DecoderOption{AllowEmptyBody()}},
+		{name: "malformed json", contentType: "application/json", body: `{"message":`, wantCode: http.StatusBadRequest},
+		{name: "malformed proto", contentType: "application/x-protobuf", body: "\xff\xff", wantCode: http.StatusBadRequest},
+		{
+			name:        "too large",
+			contentType: "application/json",
+			body:        `{"message":"hi"}`,
+			opts:        []DecoderOption{MaxBodyBytes(4)},
+			wantCode:    http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:          "too large without length",
+			contentType:   "application/json",
+			body:          `{"message":"hi"}`,
+			unknownLength: true,
+			opts:          []DecoderOption{MaxBodyBytes(4)},
+			wantCode:      http.StatusRequestEntityTooLarge,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/cat", strings.NewReader(test.body))
+			r.Header.Set("Content-Type", test.contentType)
+			if test.unknownLength {
+				r.ContentLength = -1
+				r.Body = ioutil.NopCloser(strings.NewReader(test.body))
+			}
+			res, err := DecodeRequest(newMsg, test.opts...)(context.Background(), r)
+			if test.wantCode != 0 {
+				sc, ok := err.(httptransport.StatusCoder)
+				if !ok || sc.StatusCode() != test.wantCode {
+					t.Fatalf("expected an error with status %d, got %v", test.wantCode, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+			if got := res.(*ErrorMessage).Message; got != test.wantMessage {
+				t.Errorf("expected message %q, got %q", test.wantMessage, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package marvin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/golang/protobuf/proto"
+)
+
+// Code is a canonical error code. The values match the gRPC status codes.
+type Code int
+
+// The canonical error codes.
+const (
+	CodeOK Code = iota
+	CodeCanceled
+	CodeUnknown
+	CodeInvalidArgument
+	CodeDeadlineExceeded
+	CodeNotFound
+	CodeAlreadyExists
+	CodePermissionDenied
+	CodeResourceExhausted
+	CodeFailedPrecondition
+	CodeAborted
+	CodeOutOfRange
+	CodeUnimplemented
+	CodeInternal
+	CodeUnavailable
+	CodeDataLoss
+	CodeUnauthenticated
+)
+
+var codeNames = [...]string{
+	CodeOK:                 "ok",
+	CodeCanceled:           "canceled",
+	CodeUnknown:            "unknown",
+	CodeInvalidArgument:    "invalid_argument",
+	CodeDeadlineExceeded:   "deadline_exceeded",
+	CodeNotFound:           "not_found",
+	CodeAlreadyExists:      "already_exists",
+	CodePermissionDenied:   "permission_denied",
+	CodeResourceExhausted:  "resource_exhausted",
+	CodeFailedPrecondition: "failed_precondition",
+	CodeAborted:            "aborted",
+	CodeOutOfRange:         "out_of_range",
+	CodeUnimplemented:      "unimplemented",
+	CodeInternal:           "internal",
+	CodeUnavailable:        "unavailable",
+	CodeDataLoss:           "data_loss",
+	CodeUnauthenticated:    "unauthenticated",
+}
+
+var codeStatuses = [...]int{
+	CodeOK:                 http.StatusOK,
+	CodeCanceled:           http.StatusRequestTimeout,
+	CodeUnknown:            http.StatusInternalServerError,
+	CodeInvalidArgument:    http.StatusBadRequest,
+	CodeDeadlineExceeded:   http.StatusGatewayTimeout,
+	CodeNotFound:           http.StatusNotFound,
+	CodeAlreadyExists:      http.StatusConflict,
+	CodePermissionDenied:   http.StatusForbidden,
+	CodeResourceExhausted:  http.StatusTooManyRequests,
+	CodeFailedPrecondition: http.StatusPreconditionFailed,
+	CodeAborted:            http.StatusConflict,
+	CodeOutOfRange:         http.StatusBadRequest,
+	CodeUnimplemented:      http.StatusNotImplemented,
+	CodeInternal:           http.StatusInternalServerError,
+	CodeUnavailable:        http.StatusServiceUnavailable,
+	CodeDataLoss:           http.StatusInternalServerError,
+	CodeUnauthenticated:    http.StatusUnauthorized,
+}
+
+// String returns the snake cased name of the code, like "not_found".
+func (c Code) String() string {
+	if c < 0 || int(c) >= len(codeNames) {
+		return codeNames[CodeUnknown]
+	}
+	return codeNames[c]
+}
+
+// HTTPStatus returns the HTTP status code that best describes the code.
+func (c Code) HTTPStatus() int {
+	if c < 0 || int(c) >= len(codeStatuses) {
+		return http.StatusInternalServerError
+	}
+	return codeStatuses[c]
+}
+
+// CodeFromStatus will pick the Code that best describes an HTTP status code.
+func CodeFromStatus(status int) Code {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidArgument
+	case http.StatusUnauthorized:
+		return CodeUnauthenticated
+	case http.StatusForbidden:
+		return CodePermissionDenied
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return CodeDeadlineExceeded
+	case http.StatusConflict:
+		return CodeAlreadyExists
+	case http.StatusPreconditionFailed:
+		return CodeFailedPrecondition
+	case http.StatusTooManyRequests:
+		return CodeResourceExhausted
+	case http.StatusNotImplemented:
+		return CodeUnimplemented
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	switch {
+	case status >= 200 && status < 300:
+		return CodeOK
+	case status >= 400 && status < 500:
+		return CodeInvalidArgument
+	case status == http.StatusInternalServerError:
+		return CodeInternal
+	}
+	return CodeUnknown
+}
+
+// Error is a structured error that marvin knows how to serialize for any of
+// the protocols it supports. The Code selects the status code of the response
+// while the Message and Details are sent to the caller. The optional cause is
+// only logged and never sent to the caller.
+//
+// Error works with github.com/pkg/errors: it implements Cause() and will be
+// found by the default error encoder even if it is wrapped with errors.Wrap.
+type Error struct {
+	Code    Code
+	Message string
+	Details map[string]string
+
+	cause error
+}
+
+// NewError returns an Error with the given code and message.
+func NewError(code Code, msg string) *Error {
+	return &Error{Code: code, Message: msg}
+}
+
+// Errorf returns an Error with the given code and a formatted message.
+func Errorf(code Code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// WrapError returns an Error with the given code and message that has err as its
+// cause.
+func WrapError(err error, code Code, msg string) *Error {
+	return &Error{Code: code, Message: msg, cause: err}
+}
+
+// WithDetail will add a key/value pair to the details sent to the caller.
+func (e *Error) WithDetail(key, value string) *Error {
+	if e.Details == nil {
+		e.Details = map[string]string{}
+	}
+	e.Details[key] = value
+	return e
+}
+
+// Error is to implement error. It includes the cause so it is useful in logs.
+func (e *Error) Error() string {
+	msg := e.Code.String() + ": " + e.Message
+	if e.cause != nil {
+		msg += ": " + e.cause.Error()
+	}
+	return msg
+}
+
+// Cause is to implement the github.com/pkg/errors causer interface.
+func (e *Error) Cause() error {
+	return e.cause
+}
+
+// StatusCode is to implement httptransport.StatusCoder
+func (e *Error) StatusCode() int {
+	return e.Code.HTTPStatus()
+}
+
+// MarshalJSON is to implement json.Marshaler. The cause is never included.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.proto())
+}
+
+// proto returns the message sent to callers.
+func (e *Error) proto() *ErrorMessage {
+	return &ErrorMessage{
+		Message: e.Message,
+		Code:    e.Code.String(),
+		Details: e.Details,
+	}
+}
+
+type causer interface {
+	Cause() error
+}
+
+// ErrorFrom returns the first *Error in the github.com/pkg/errors cause chain of err
+// or nil if there is none.
+func ErrorFrom(err error) *Error {
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			return e
+		}
+		c, ok := err.(causer)
+		if !ok {
+			return nil
+		}
+		err = c.Cause()
+	}
+	return nil
+}
+
+// statusFrom returns the first error in the github.com/pkg/errors cause chain of
+// err that is an *Error or implements httptransport.StatusCoder, or nil if there
+// is none.
+func statusFrom(err error) error {
+	for err != nil {
+		switch err.(type) {
+		case *Error, httptransport.StatusCoder:
+			return err
+		}
+		c, ok := err.(causer)
+		if !ok {
+			return nil
+		}
+		err = c.Cause()
+	}
+	return nil
+}
+
+// toError will convert any error into an *Error that is safe to send to callers.
+// Errors without a status code in their cause chain become internal errors with a
+// generic message.
+func toError(err error) *Error {
+	status := statusFrom(err)
+	if e, ok := status.(*Error); ok {
+		if e.Code == CodeOK {
+			return &Error{Code: CodeUnknown, Message: e.Message, Details: e.Details, cause: e.cause}
+		}
+		return e
+	}
+	sc, ok := status.(httptransport.StatusCoder)
+	if !ok {
+		return WrapError(err, CodeInternal, "internal error")
+	}
+	code := CodeFromStatus(sc.StatusCode())
+	switch {
+	case code == CodeOK:
+		code = CodeUnknown
+		fallthrough
+	case sc.StatusCode() >= http.StatusInternalServerError:
+		return NewError(code, http.StatusText(sc.StatusCode()))
+	}
+	return NewError(code, errorMessage(status))
+}
+
+// EncodeError is the default httptransport.ErrorEncoder for all marvin endpoints.
+// It serializes errors in the format selected for the request.
+//
+// The first *Error in the cause chain will be sent with the status code of its Code
+// and its message and details as the body. Errors in the chain that implement
+// StatusCoder, like ProtoStatusResponse and JSONStatusResponse, are sent as they
+// are. All other errors respond with a generic 500. The full error chain of
+// internal errors and errors with a cause is logged but never sent to the caller.
+//
+// If the ProblemDocuments option is enabled, all errors will be sent as problem
+// documents instead.
+func EncodeError(ctx context.Context, err error, w http.ResponseWriter) {
+	status := statusFrom(err)
+	if _, ok := status.(*ProblemResponse); ok {
+		encodeStatusError(ctx, status, w)
+		return
+	}
+	_, isError := status.(*Error)
+	sc, isStatusCoder := status.(httptransport.StatusCoder)
+	if !isError && isStatusCoder && !problemsEnabled(ctx) {
+		encodeStatusError(ctx, status, w)
+		return
+	}
+	e := toError(err)
+	if e.cause != nil || e.StatusCode() >= http.StatusInternalServerError {
+		logErrorf(ctx, "error serving request: %+v", err)
+	}
+	if problemsEnabled(ctx) {
+		p := e.toProblem()
+		if !isError && isStatusCoder {
+			// keep the status code the service chose
+			p.Status, p.Title = sc.StatusCode(), http.StatusText(sc.StatusCode())
+		}
+		encodeStatusError(ctx, p, w)
+		return
+	}
+	if ContextFormat(ctx) == FormatProto {
+		EncodeProtoResponse(ctx, w, NewProtoStatusResponse(e.proto(), e.StatusCode()))
+		return
+	}
+	httptransport.DefaultErrorEncoder(ctx, e, w)
+}
+
+// encodeStatusError will send status response errors in the negotiated format.
+func encodeStatusError(ctx context.Context, err error, w http.ResponseWriter) {
+	if ContextFormat(ctx) == FormatProto {
+		if _, ok := err.(proto.Message); ok {
+			EncodeProtoResponse(ctx, w, err)
+			return
+		}
+	}
+	// some go-kit versions add the Headerer values after their own Content-Type
+	// instead of replacing it, so JSON bodies are written with the codec
+	if _, ok := err.(json.Marshaler); ok {
+		if DefaultJSONCodec.EncodeResponse(ctx, w, err) == nil {
+			return
+		}
+	}
+	httptransport.DefaultErrorEncoder(ctx, err, w)
+}
@@ -0,0 +1,88 @@
+package marvin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestEncodeError(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   Format
+		problems bool
+		err      error
+
+		wantCode        int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:     "error",
+			err:      NewError(CodeNotFound, "no cat"),
+			wantCode: http.StatusNotFound,
+			wantBody: `"message":"no cat"`,
+		},
+		{
+			name:     "wrapped error",
+			err:      errors.Wrap(NewError(CodeNotFound, "no cat"), "finding cat"),
+			wantCode: http.StatusNotFound,
+			wantBody: `"message":"no cat"`,
+		},
+		{
+			name:     "wrapped JSON status response",
+			err:      errors.Wrap(NewJSONStatusResponse(map[string]string{"msg": "gone"}, http.StatusGone), "finding cat"),
+			wantCode: http.StatusGone,
+			wantBody: `{"msg":"gone"}`,
+		},
+		{
+			name:     "wrapped proto status response",
+			format:   FormatProto,
+			err:      errors.Wrap(NewProtoStatusResponse(&ErrorMessage{Message: "no cat"}, http.StatusNotFound), "finding cat"),
+			wantCode: http.StatusNotFound,
+			wantBody: "no cat",
+		},
+		{
+			name:            "problem document",
+			problems:        true,
+			err:             NewError(CodeNotFound, "no cat"),
+			wantCode:        http.StatusNotFound,
+			wantContentType: ProblemContentType,
+			wantBody:        `"code":"not_found"`,
+		},
+		{
+			name:            "problem response",
+			err:             NewProblemResponse(http.StatusConflict, "cat exists"),
+			wantCode:        http.StatusConflict,
+			wantContentType: ProblemContentType,
+			wantBody:        `"detail":"cat exists"`,
+		},
+		{
+			name:     "plain error",
+			err:      errors.New("database password is hunter2"),
+			wantCode: http.StatusInternalServerError,
+			wantBody: `"message":"internal error"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), ContextKeyFormat, test.format)
+			ctx = context.WithValue(ctx, problemsKey, test.problems)
+			w := httptest.NewRecorder()
+			EncodeError(ctx, test.err, w)
+			if w.Code != test.wantCode {
+				t.Errorf("expected status %d, got %d", test.wantCode, w.Code)
+			}
+			if test.wantContentType != "" && w.Header().Get("Content-Type") != test.wantContentType {
+				t.Errorf("expected content type %q, got %q", test.wantContentType, w.Header().Get("Content-Type"))
+			}
+			if !strings.Contains(w.Body.String(), test.wantBody) {
+				t.Errorf("expected body to contain %q, got %q", test.wantBody, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package marvin
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+)
+
+// Format is the serialization used for a request or response body.
+type Format string
+
+const (
+	// FormatJSON is used for JSON serialized bodies.
+	FormatJSON Format = "json"
+	// FormatProto is used for Protobuf serialized bodies.
+	FormatProto Format = "proto"
+	// FormatNegotiated marks endpoints from a NegotiatedEndpointer that
+	// will serve either format depending on the request.
+	FormatNegotiated Format = "negotiated"
+)
+
+// formatMediaTypes maps the media types marvin understands to their Format.
+var formatMediaTypes = map[string]Format{
+	"application/json":         FormatJSON,
+	"text/json":                FormatJSON,
+	"application/x-protobuf":   FormatProto,
+	"application/protobuf":     FormatProto,
+	"application/octet-stream": FormatProto,
+}
+
+// mediaTypeFormat will return the Format for the given media type or an
+// empty string if the type is unknown.
+func mediaTypeFormat(mt string) Format {
+	mt = strings.ToLower(strings.TrimSpace(mt))
+	if f, ok := formatMediaTypes[mt]; ok {
+		return f
+	}
+	if strings.HasSuffix(mt, "+json") {
+		return FormatJSON
+	}
+	return ""
+}
+
+// RequestFormat returns the Format of the request body based on its
+// 'Content-Type' header. If the header is missing or unknown, FormatJSON
+// will be returned.
+func RequestFormat(r *http.Request) Format {
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return FormatJSON
+	}
+	if f := mediaTypeFormat(mt); f != "" {
+		return f
+	}
+	return FormatJSON
+}
+
+// ResponseFormat returns the Format the caller would like to receive. A '.json'
+// or '.proto' suffix on the request path takes precedence, followed by the
+// most preferred known media type in the 'Accept' header. If neither is
+// conclusive, the format of the request body is used.
+func ResponseFormat(r *http.Request) Format {
+	switch {
+	case strings.HasSuffix(r.URL.Path, ".json"):
+		return FormatJSON
+	case strings.HasSuffix(r.URL.Path, ".proto"):
+		return FormatProto
+	}
+	if f := acceptFormat(r.Header.Get("Accept")); f != "" {
+		return f
+	}
+	return RequestFormat(r)
+}
+
+// acceptFormat will return the known Format with the highest quality value in
+// the given 'Accept' header. Ties go to the first listed type.
+func acceptFormat(accept string) Format {
+	var (
+		best  Format
+		bestQ float64
+	)
+	for _, rng := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(rng)
+		if err != nil {
+			continue
+		}
+		f := mediaTypeFormat(mt)
+		if f == "" {
+			continue
+		}
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(qs, 64); err != nil {
+				continue
+			}
+		}
+		if q > bestQ {
+			best, bestQ = f, q
+		}
+	}
+	return best
+}
+
+// ContextFormat returns the response Format marvin selected for the current
+// request. It will return an empty string if no format has been selected.
+func ContextFormat(ctx context.Context) Format {
+	f, _ := ctx.Value(ContextKeyFormat).(Format)
+	return f
+}
+
+// NegotiatedDecoder returns an httptransport.DecodeRequestFunc that will hand the
+// request to the JSON or Protobuf decoder based on the request's 'Content-Type'
+// header. This is meant to be used as the Decoder for NegotiatedEndpoints that
+// accept a request body.
+func NegotiatedDecoder(jsonDec, protoDec httptransport.DecodeRequestFunc) httptransport.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		if RequestFormat(r) == FormatProto {
+			return protoDec(ctx, r)
+		}
+		return jsonDec(ctx, r)
+	}
+}
+
+// EncodeResponse is an httptransport.EncodeResponseFunc that serializes the response
+// as JSON or Protobuf, depending on the format negotiated for the request. This is
+// the default encoder for NegotiatedEndpoints.
+func EncodeResponse(ctx context.Context, w http.ResponseWriter, res interface{}) error {
+	if ContextFormat(ctx) == FormatProto {
+		return EncodeProtoResponse(ctx, w, res)
+	}
+	return EncodeJSONResponse(ctx, w, res)
+}
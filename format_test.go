@@ -0,0 +1,120 @@
+package marvin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+)
+
+func TestResponseFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		accept      string
+		contentType string
+
+		want Format
+	}{
+		{name: "default", path: "/cat", want: FormatJSON},
+		{name: "json suffix", path: "/cat.json", accept: "application/x-protobuf", want: FormatJSON},
+		{name: "proto suffix", path: "/cat.proto", accept: "application/json", want: FormatProto},
+		{name: "accept", path: "/cat", accept: "application/x-protobuf", want: FormatProto},
+		{name: "accept quality", path: "/cat", accept: "application/json;q=0.5, application/protobuf;q=0.9", want: FormatProto},
+		{name: "accept suffix", path: "/cat", accept: "application/vnd.cat+json", contentType: "application/x-protobuf", want: FormatJSON},
+		{name: "unknown accept", path: "/cat", accept: "text/html", contentType: "application/x-protobuf", want: FormatProto},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, test.path, nil)
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+			if test.contentType != "" {
+				r.Header.Set("Content-Type", test.contentType)
+			}
+			if got := ResponseFormat(r); got != test.want {
+				t.Errorf("expected format %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+type negotiatedService struct {
+	testService
+}
+
+func (s negotiatedService) NegotiatedEndpoints() map[string]map[string]HTTPEndpoint {
+	return map[string]map[string]HTTPEndpoint{
+		"/cat": {
+			"POST": {
+				Endpoint: func(_ context.Context, req interface{}) (interface{}, error) {
+					return &ErrorMessage{Message: req.(string)}, nil
+				},
+				Decoder: NegotiatedDecoder(
+					func(context.Context, *http.Request) (interface{}, error) { return "json", nil },
+					func(context.Context, *http.Request) (interface{}, error) { return "proto", nil },
+				),
+			},
+		},
+	}
+}
+
+func TestNegotiatedEndpoint(t *testing.T) {
+	svr := newTestServer(t, negotiatedService{})
+
+	tests := []struct {
+		name        string
+		contentType string
+		accept      string
+
+		wantContentType string
+		wantMessage     string
+	}{
+		{
+			name:            "json",
+			contentType:     "application/json",
+			wantContentType: "application/json",
+			wantMessage:     "json",
+		},
+		{
+			name:            "proto",
+			contentType:     "application/x-protobuf",
+			wantContentType: "application/x-protobuf",
+			wantMessage:     "proto",
+		},
+		{
+			name:            "json request for proto",
+			contentType:     "application/json",
+			accept:          "application/x-protobuf",
+			wantContentType: "application/x-protobuf",
+			wantMessage:     "json",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := serve(svr, http.MethodPost, "/cat", "", "Content-Type", test.contentType, "Accept", test.accept)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body)
+			}
+			ct := w.Header().Get("Content-Type")
+			if !strings.HasPrefix(ct, test.wantContentType) {
+				t.Fatalf("expected content type %q, got %q", test.wantContentType, ct)
+			}
+			var msg ErrorMessage
+			if test.wantContentType == "application/x-protobuf" {
+				if err := proto.Unmarshal(w.Body.Bytes(), &msg); err != nil {
+					t.Fatalf("unable to parse response: %s", err)
+				}
+			} else if err := DefaultJSONCodec.Unmarshal(w.Body.Bytes(), &msg); err != nil {
+				t.Fatalf("unable to parse response: %s", err)
+			}
+			if msg.Message != test.wantMessage {
+				t.Errorf("expected message %q, got %q", test.wantMessage, msg.Message)
+			}
+		})
+	}
+}
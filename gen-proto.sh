@@ -0,0 +1,4 @@
+#!/bin/sh
+
+# for the messages marvin uses to describe errors
+protoc --go_out=. marvin.proto;
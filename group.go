@@ -0,0 +1,183 @@
+package marvin
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+)
+
+// Grouper can be implemented by a Service to register groups of endpoints in
+// addition to, or instead of, its endpoint maps.
+type Grouper interface {
+	Groups() []Group
+}
+
+// Group is a fragment of a service's endpoints that share a path prefix, go-kit
+// middleware and server options. For example, to serve two versions of an API:
+//
+//	return []marvin.Group{
+//		{
+//			Prefix:        "/v1",
+//			Middleware:    s.legacyAuth,
+//			JSONEndpoints: s.v1Endpoints(),
+//		},
+//		{
+//			Prefix:        "/v2",
+//			JSONEndpoints: s.v2Endpoints(),
+//		},
+//	}
+//
+// Groups may also share a path and be selected by the 'Accept-Version' request
+// header by giving each group a Version. Requests without the header are served
+// by the group without a Version, if there is one, or the latest version. Requests
+// for a version that does not exist will get a 406 response.
+//
+// Every path and method may only be registered once per version across all of a
+// service's endpoint maps and groups.
+type Group struct {
+	// Prefix is added to the start of every path in the group. Leading and
+	// trailing slashes are collapsed, so "/v1/" and "/cats" become "/v1/cats".
+	Prefix string
+	// Version is matched against the 'Accept-Version' request header.
+	Version string
+	// Middleware wraps every endpoint in the group, outside of the endpoints'
+	// own Middleware. The service's Middleware will still wrap the group's
+	// unless an endpoint skips it.
+	Middleware endpoint.Middleware
+	// Options are added after the service's Options and before the
+	// options of each endpoint.
+	Options []httptransport.ServerOption
+
+	JSONEndpoints       map[string]map[string]HTTPEndpoint
+	ProtoEndpoints      map[string]map[string]HTTPEndpoint
+	NegotiatedEndpoints map[string]map[string]HTTPEndpoint
+}
+
+// sets will flatten the group into endpoint sets with the prefix, middleware and
+// options applied to each endpoint.
+func (g Group) sets() []endpointSet {
+	var sets []endpointSet
+	for _, s := range []endpointSet{
+		{format: FormatJSON, endpoints: g.JSONEndpoints},
+		{format: FormatProto, endpoints: g.ProtoEndpoints},
+		{format: FormatNegotiated, endpoints: g.NegotiatedEndpoints},
+	} {
+		if len(s.endpoints) == 0 {
+			continue
+		}
+		eps := make(map[string]map[string]HTTPEndpoint, len(s.endpoints))
+		for path, epMethods := range s.endpoints {
+			methods := make(map[string]HTTPEndpoint, len(epMethods))
+			for method, ep := range epMethods {
+				if g.Middleware != nil {
+					ep.Middleware = append([]endpoint.Middleware{g.Middleware}, ep.Middleware...)
+				}
+				if len(g.Options) > 0 {
+					ep.Options = append(append([]httptransport.ServerOption{}, g.Options...), ep.Options...)
+				}
+				methods[method] = ep
+			}
+			eps[joinPrefix(g.Prefix, path)] = methods
+		}
+		sets = append(sets, endpointSet{format: s.format, endpoints: eps, version: g.Version})
+	}
+	return sets
+}
+
+// joinPrefix will add the prefix to the path with a single '/' between them, so
+// "/v1/" and "/cats" become "/v1/cats".
+func joinPrefix(prefix, path string) string {
+	if prefix == "" {
+		return path
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// versionedHandler is the handler for one version of a path and method.
+type versionedHandler struct {
+	version string
+	handler http.Handler
+	route   Route
+}
+
+// hasVersion returns true if one of the handlers is for the version.
+func hasVersion(hs []versionedHandler, version string) bool {
+	for _, h := range hs {
+		if h.version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// sharesVersion returns true if both lists have a handler for the same version.
+func sharesVersion(a, b []versionedHandler) bool {
+	for _, h := range a {
+		if hasVersion(b, h.version) {
+			return true
+		}
+	}
+	return false
+}
+
+// versionHandler returns a handler that picks one of the given handlers with the
+// 'Accept-Version' request header.
+func versionHandler(hs []versionedHandler) http.Handler {
+	if len(hs) == 1 && hs[0].version == "" {
+		return hs[0].handler
+	}
+	// sort so the default is first: no version, then the latest version
+	hs = append([]versionedHandler{}, hs...)
+	sort.Slice(hs, func(i, j int) bool {
+		return defaultVersionFirst(hs[i].version, hs[j].version)
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Version")
+		want := strings.TrimSpace(r.Header.Get("Accept-Version"))
+		if want == "" {
+			hs[0].handler.ServeHTTP(w, r)
+			return
+		}
+		for _, h := range hs {
+			if h.version == want {
+				h.handler.ServeHTTP(w, r)
+				return
+			}
+		}
+		http.Error(w, "unsupported version: "+want, http.StatusNotAcceptable)
+	})
+}
+
+// defaultVersionFirst orders versions the way they are picked for requests without
+// an 'Accept-Version' header: no version first, then the latest.
+func defaultVersionFirst(a, b string) bool {
+	if a == "" || b == "" {
+		return a == ""
+	}
+	return compareVersions(a, b) > 0
+}
+
+// compareVersions will compare dot separated versions like "v1.10" and "v1.9"
+// segment by segment, numerically where possible. A leading 'v' is ignored.
+func compareVersions(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aerr := strconv.Atoi(as[i])
+		bn, berr := strconv.Atoi(bs[i])
+		switch {
+		case aerr == nil && berr == nil && an != bn:
+			if an < bn {
+				return -1
+			}
+			return 1
+		case (aerr != nil || berr != nil) && as[i] != bs[i]:
+			return strings.Compare(as[i], bs[i])
+		}
+	}
+	return len(as) - len(bs)
+}
@@ -0,0 +1,95 @@
+package marvin
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+func TestGroups(t *testing.T) {
+	shout := func(ep endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			res, err := ep(ctx, req)
+			return strings.ToUpper(res.(string)), err
+		}
+	}
+	svr := newTestServer(t, groupService{groups: []Group{
+		{
+			Prefix:        "/v1/",
+			Middleware:    shout,
+			JSONEndpoints: map[string]map[string]HTTPEndpoint{"/cats": {"GET": testEndpoint("v1 cats")}},
+		},
+		{
+			Prefix:        "/api",
+			Version:       "v1.9",
+			JSONEndpoints: map[string]map[string]HTTPEndpoint{"/dog": {"GET": testEndpoint("v1.9 dog")}},
+		},
+		{
+			Prefix:        "/api",
+			Version:       "v1.10",
+			JSONEndpoints: map[string]map[string]HTTPEndpoint{"/dog": {"GET": testEndpoint("v1.10 dog")}},
+		},
+		{
+			Prefix:        "/api",
+			JSONEndpoints: map[string]map[string]HTTPEndpoint{"/cat": {"GET": testEndpoint("cat")}},
+		},
+		{
+			Prefix:        "/api",
+			Version:       "v2",
+			JSONEndpoints: map[string]map[string]HTTPEndpoint{"/cat": {"GET": testEndpoint("v2 cat")}},
+		},
+	}})
+
+	tests := []struct {
+		name    string
+		path    string
+		version string
+
+		wantCode int
+		wantBody string
+	}{
+		{name: "prefix", path: "/v1/cats", wantCode: http.StatusOK, wantBody: `"V1 CATS"`},
+		{name: "latest version", path: "/api/dog", wantCode: http.StatusOK, wantBody: `"v1.10 dog"`},
+		{name: "version", path: "/api/dog", version: "v1.9", wantCode: http.StatusOK, wantBody: `"v1.9 dog"`},
+		{name: "unknown version", path: "/api/dog", version: "v3", wantCode: http.StatusNotAcceptable},
+		{name: "unversioned default", path: "/api/cat", wantCode: http.StatusOK, wantBody: `"cat"`},
+		{name: "version over unversioned", path: "/api/cat", version: "v2", wantCode: http.StatusOK, wantBody: `"v2 cat"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := serve(svr, http.MethodGet, test.path, "", "Accept-Version", test.version)
+			if w.Code != test.wantCode {
+				t.Fatalf("expected status %d, got %d: %s", test.wantCode, w.Code, w.Body)
+			}
+			if test.wantBody != "" && strings.TrimSpace(w.Body.String()) != test.wantBody {
+				t.Errorf("expected body %s, got %s", test.wantBody, w.Body)
+			}
+			if strings.HasPrefix(test.path, "/api") && w.Header().Get("Vary") != "Accept-Version" {
+				t.Errorf("expected to vary by Accept-Version, got %q", w.Header().Get("Vary"))
+			}
+		})
+	}
+}
+
+func TestJoinPrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		path   string
+		want   string
+	}{
+		{prefix: "", path: "/cats", want: "/cats"},
+		{prefix: "/v1", path: "/cats", want: "/v1/cats"},
+		{prefix: "/v1/", path: "/cats", want: "/v1/cats"},
+		{prefix: "/v1", path: "cats", want: "/v1/cats"},
+		{prefix: "/v1/", path: "/cats/", want: "/v1/cats/"},
+		{prefix: "/v1", path: "/", want: "/v1/"},
+	}
+	for _, test := range tests {
+		if got := joinPrefix(test.prefix, test.path); got != test.want {
+			t.Errorf("joinPrefix(%q, %q) = %q, want %q", test.prefix, test.path, got, test.want)
+		}
+	}
+}
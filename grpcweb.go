@@ -0,0 +1,229 @@
+package marvin
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/golang/protobuf/proto"
+)
+
+// GRPCWebEndpointer can be implemented by a Service to expose an RPCService via the
+// gRPC-Web protocol so browser clients generated by grpc-web can call it without
+// a separate proxy. Each method will be served at `POST /<package>.<Service>/<Method>`
+// and accept 'application/grpc-web' as well as the base64 encoded
+// 'application/grpc-web-text' wire format.
+//
+// gRPC-Web always responds with a 200 status code. The status of a call is sent in
+// the 'grpc-status' and 'grpc-message' trailers, with the status codes of errors
+// mapped to their closest gRPC equivalent.
+type GRPCWebEndpointer interface {
+	GRPCWebService() RPCService
+}
+
+const (
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+
+	grpcDataFrame    = 0x00
+	grpcTrailerFrame = 0x80
+)
+
+// registerGRPCWeb will register all the methods of the RPCService as gRPC-Web routes.
+func (s Server) registerGRPCWeb(svc Service, rpc RPCService) {
+	for _, name := range rpc.methodNames() {
+		m := rpc.Methods[name]
+		opts := append([]httptransport.ServerOption{}, defaultOpts...)
+		opts = append(opts, httptransport.ServerBefore(
+			func(ctx context.Context, r *http.Request) context.Context {
+				ctx = context.WithValue(ctx, ContextKeyFormat, FormatProto)
+				return context.WithValue(ctx, grpcWebTextKey, isGRPCWebText(r))
+			}))
+		opts = append(opts, svc.Options()...)
+		opts = append(opts, m.Options...)
+		// gRPC-Web clients require gRPC statuses
+		opts = append(opts, httptransport.ServerErrorEncoder(encodeGRPCWebError))
+
+		path := "/" + rpc.fullName() + "/" + name
+		s.handle(http.MethodPost, path,
+			m.handler(svc, decodeGRPCWebRequest(m.NewRequest), encodeGRPCWebResponse, opts))
+		s.routes.add(Route{
+			Method:   http.MethodPost,
+			Path:     path,
+			Format:   FormatProto,
+			Protocol: "grpc-web",
+		})
+	}
+}
+
+type grpcWebKey int
+
+// grpcWebTextKey marks requests that use the base64 text wire format.
+const grpcWebTextKey grpcWebKey = 0
+
+func isGRPCWebText(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), grpcWebTextContentType)
+}
+
+// grpcWebError is a gRPC status.
+type grpcWebError struct {
+	code Code
+	msg  string
+}
+
+func (g grpcWebError) Error() string {
+	return "grpc status " + strconv.Itoa(int(g.code)) + ": " + g.msg
+}
+
+func decodeGRPCWebRequest(newMsg ProtoFactory) httptransport.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), grpcWebContentType) {
+			return nil, grpcWebError{CodeInvalidArgument,
+				"unexpected Content-Type: " + r.Header.Get("Content-Type")}
+		}
+		var body io.Reader = io.LimitReader(r.Body, DefaultMaxBodyBytes)
+		if isGRPCWebText(r) {
+			body = &grpcWebTextReader{r: body}
+		}
+		var hdr [5]byte
+		if _, err := io.ReadFull(body, hdr[:]); err != nil {
+			return nil, grpcWebError{CodeInvalidArgument, "unable to read message frame"}
+		}
+		if hdr[0] != grpcDataFrame {
+			return nil, grpcWebError{CodeUnimplemented, "compressed messages are not supported"}
+		}
+		// the length comes from the client, so check it before allocating
+		size := binary.BigEndian.Uint32(hdr[1:])
+		if int64(size) > DefaultMaxBodyBytes {
+			return nil, grpcWebError{CodeResourceExhausted, "message is too large"}
+		}
+		b := make([]byte, size)
+		if _, err := io.ReadFull(body, b); err != nil {
+			return nil, grpcWebError{CodeInvalidArgument, "unable to read message"}
+		}
+		msg := newMsg()
+		if err := proto.Unmarshal(b, msg); err != nil {
+			return nil, grpcWebError{CodeInvalidArgument, "unable to parse request: malformed protobuf"}
+		}
+		return msg, nil
+	}
+}
+
+// grpcWebTextReader decodes the base64 text wire format. Clients may send
+// several separately padded base64 chunks so the body is decoded in 4 byte
+// quantums instead of as a single base64 string.
+type grpcWebTextReader struct {
+	r   io.Reader
+	buf bytes.Buffer
+}
+
+func (g *grpcWebTextReader) Read(p []byte) (int, error) {
+	for g.buf.Len() < len(p) {
+		var q [4]byte
+		n, err := io.ReadFull(g.r, q[:])
+		if n == 0 && err != nil {
+			if g.buf.Len() > 0 {
+				break
+			}
+			return 0, err
+		}
+		if err != nil {
+			return 0, io.ErrUnexpectedEOF
+		}
+		var out [3]byte
+		n, err = base64.StdEncoding.Decode(out[:], q[:])
+		if err != nil {
+			return 0, err
+		}
+		g.buf.Write(out[:n])
+	}
+	return g.buf.Read(p)
+}
+
+func encodeGRPCWebResponse(ctx context.Context, w http.ResponseWriter, res interface{}) error {
+	// some middlewares, like AllowIPNets, deny requests with a response
+	if sc, ok := res.(httptransport.StatusCoder); ok && sc.StatusCode() >= http.StatusBadRequest {
+		if err, ok := res.(error); ok {
+			encodeGRPCWebError(ctx, err, w)
+			return nil
+		}
+	}
+	msg, ok := res.(proto.Message)
+	if !ok {
+		return grpcWebError{CodeInternal, "response does not implement proto.Message"}
+	}
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		return grpcWebError{CodeInternal, "unable to serialize response"}
+	}
+	var body bytes.Buffer
+	writeGRPCWebFrame(&body, grpcDataFrame, b)
+	writeGRPCWebFrame(&body, grpcTrailerFrame, grpcWebTrailers(CodeOK, ""))
+	writeGRPCWeb(ctx, w, body.Bytes())
+	return nil
+}
+
+// encodeGRPCWebError is an httptransport.ErrorEncoder that responds with a gRPC
+// status in both the headers and trailers of the response.
+func encodeGRPCWebError(ctx context.Context, err error, w http.ResponseWriter) {
+	ge, ok := err.(grpcWebError)
+	if !ok {
+		e := toError(err)
+		if e.cause != nil {
+			logErrorf(ctx, "error serving request: %+v", err)
+		}
+		ge = grpcWebError{e.Code, e.Message}
+	}
+	w.Header().Set("grpc-status", strconv.Itoa(int(ge.code)))
+	w.Header().Set("grpc-message", grpcWebMessage(ge.msg))
+	var body bytes.Buffer
+	writeGRPCWebFrame(&body, grpcTrailerFrame, grpcWebTrailers(ge.code, ge.msg))
+	writeGRPCWeb(ctx, w, body.Bytes())
+}
+
+// writeGRPCWeb will write the given frames in the wire format of the request.
+func writeGRPCWeb(ctx context.Context, w http.ResponseWriter, body []byte) {
+	ct := grpcWebContentType + "+proto"
+	if text, _ := ctx.Value(grpcWebTextKey).(bool); text {
+		ct = grpcWebTextContentType + "+proto"
+		body = []byte(base64.StdEncoding.EncodeToString(body))
+	}
+	w.Header().Set("Content-Type", ct)
+	w.Header().Set("Access-Control-Expose-Headers", "grpc-status, grpc-message")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+func writeGRPCWebFrame(buf *bytes.Buffer, flag byte, b []byte) {
+	var hdr [5]byte
+	hdr[0] = flag
+	binary.BigEndian.PutUint32(hdr[1:], uint32(len(b)))
+	buf.Write(hdr[:])
+	buf.Write(b)
+}
+
+func grpcWebTrailers(code Code, msg string) []byte {
+	return []byte("grpc-status:" + strconv.Itoa(int(code)) + "\r\n" +
+		"grpc-message:" + grpcWebMessage(msg) + "\r\n")
+}
+
+// grpcWebMessage will percent encode a status message as the gRPC protocol requires.
+func grpcWebMessage(msg string) string {
+	var out bytes.Buffer
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			out.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&out, "%%%02X", c)
+	}
+	return out.String()
+}
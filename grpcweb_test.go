@@ -0,0 +1,151 @@
+package marvin
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"testing"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/golang/protobuf/proto"
+)
+
+type grpcWebService struct {
+	testService
+}
+
+func (s grpcWebService) GRPCWebService() RPCService {
+	return RPCService{
+		Package: "test",
+		Name:    "EchoService",
+		Methods: map[string]RPCMethod{
+			"Echo": {
+				Endpoint: func(_ context.Context, req interface{}) (interface{}, error) {
+					msg := req.(*ErrorMessage)
+					if msg.Message == "fail" {
+						return nil, NewError(CodeNotFound, "no such echo")
+					}
+					return &ErrorMessage{Message: "echo " + msg.Message}, nil
+				},
+				NewRequest: func() proto.Message { return &ErrorMessage{} },
+				Middleware: []endpoint.Middleware{func(ep endpoint.Endpoint) endpoint.Endpoint {
+					return func(ctx context.Context, req interface{}) (interface{}, error) {
+						if req.(*ErrorMessage).Message == "deny" {
+							return nil, NewError(CodePermissionDenied, "echo denied")
+						}
+						return ep(ctx, req)
+					}
+				}},
+			},
+		},
+	}
+}
+
+func grpcWebFrame(flag byte, size uint32, b []byte) string {
+	var hdr [5]byte
+	hdr[0] = flag
+	binary.BigEndian.PutUint32(hdr[1:], size)
+	return string(hdr[:]) + string(b)
+}
+
+func grpcWebMessageFrame(msg string) string {
+	b, _ := proto.Marshal(&ErrorMessage{Message: msg})
+	return grpcWebFrame(grpcDataFrame, uint32(len(b)), b)
+}
+
+func TestGRPCWeb(t *testing.T) {
+	svr := newTestServer(t, grpcWebService{})
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+
+		wantStatus  string
+		wantMessage string
+	}{
+		{
+			name:        "binary",
+			contentType: grpcWebContentType,
+			body:        grpcWebMessageFrame("hi"),
+			wantMessage: "echo hi",
+		},
+		{
+			name:        "text",
+			contentType: grpcWebTextContentType,
+			body:        base64.StdEncoding.EncodeToString([]byte(grpcWebMessageFrame("hi"))),
+			wantMessage: "echo hi",
+		},
+		{
+			name:        "error",
+			contentType: grpcWebContentType,
+			body:        grpcWebMessageFrame("fail"),
+			wantStatus:  "5",
+		},
+		{
+			name:        "middleware",
+			contentType: grpcWebContentType,
+			body:        grpcWebMessageFrame("deny"),
+			wantStatus:  "7",
+		},
+		{
+			name:        "bad content type",
+			contentType: "application/json",
+			body:        grpcWebMessageFrame("hi"),
+			wantStatus:  "3",
+		},
+		{
+			name:        "compressed",
+			contentType: grpcWebContentType,
+			body:        grpcWebFrame(1, 0, nil),
+			wantStatus:  "12",
+		},
+		{
+			name:        "short frame",
+			contentType: grpcWebContentType,
+			body:        "\x00\x00",
+			wantStatus:  "3",
+		},
+		{
+			name:        "frame too large",
+			contentType: grpcWebContentType,
+			body:        grpcWebFrame(grpcDataFrame, 0xFFFFFFFF, nil),
+			wantStatus:  "8",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := serve(svr, http.MethodPost, "/test.EchoService/Echo", test.body,
+				"Content-Type", test.contentType)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			if got := w.Header().Get("grpc-status"); got != test.wantStatus {
+				t.Errorf("expected grpc-status %q, got %q", test.wantStatus, got)
+			}
+			if test.wantMessage == "" {
+				return
+			}
+			body := w.Body.Bytes()
+			if test.contentType == grpcWebTextContentType {
+				body, _ = base64.StdEncoding.DecodeString(string(body))
+			}
+			if len(body) < 5 || body[0] != grpcDataFrame {
+				t.Fatalf("expected a data frame, got %q", body)
+			}
+			size := binary.BigEndian.Uint32(body[1:5])
+			var msg ErrorMessage
+			if err := proto.Unmarshal(body[5:5+size], &msg); err != nil {
+				t.Fatalf("unable to parse response: %s", err)
+			}
+			if msg.Message != test.wantMessage {
+				t.Errorf("expected message %q, got %q", test.wantMessage, msg.Message)
+			}
+			if !bytes.Contains(body[5+size:], []byte("grpc-status:0")) {
+				t.Errorf("expected an OK trailer, got %q", body[5+size:])
+			}
+		})
+	}
+}
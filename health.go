@@ -0,0 +1,244 @@
+package marvin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultHealthPath is where a HealthChecker's liveness checks are served.
+	DefaultHealthPath = "/_ah/health"
+	// DefaultReadyPath is where all of a HealthChecker's checks are served.
+	DefaultReadyPath = "/readyz"
+
+	// DefaultHealthCacheTTL is how long health check results are reused for.
+	DefaultHealthCacheTTL = 5 * time.Second
+	// DefaultHealthCheckTimeout is the time each HealthCheck has to complete
+	// unless it sets its own Timeout.
+	DefaultHealthCheckTimeout = 5 * time.Second
+)
+
+// HealthCheck is a named check of something a service depends on, like a
+// datastore being reachable, config being loaded or a downstream service being up.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+	// Liveness checks are run for the health path as well as the ready path.
+	// All other checks are only run for the ready path.
+	Liveness bool
+	// Timeout is the time the check has to complete. DefaultHealthCheckTimeout
+	// is used if it is not set.
+	Timeout time.Duration
+}
+
+// HealthChecker can be implemented by a Service to serve liveness and readiness
+// probes. The liveness checks are served at /_ah/health and every check is served
+// at /readyz by default. The checks run concurrently and the response is a JSON
+// HealthReport with a 200 status if all of the checks pass or a 503 otherwise.
+// Once the Server is Stopping, the ready path will always respond with a 503.
+//
+// Results are cached for 5 seconds by default so frequent probes do not overload
+// the service's dependencies. Use the HealthPaths and HealthCacheTTL options to
+// change the defaults.
+type HealthChecker interface {
+	HealthChecks() []HealthCheck
+}
+
+// HealthPaths will set the paths for the liveness and readiness probes of a
+// HealthChecker. An empty path will disable the probe.
+func HealthPaths(health, ready string) ServerOption {
+	return func(s *Server) {
+		s.healthPath, s.readyPath = health, ready
+	}
+}
+
+// HealthCacheTTL will set how long health check results are reused for. A
+// negative TTL will disable caching.
+func HealthCacheTTL(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.healthCacheTTL = d
+	}
+}
+
+// HealthReport is the response of the health and ready paths.
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// StatusCode is to implement httptransport.StatusCoder
+func (h HealthReport) StatusCode() int {
+	if h.Status != "ok" {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
+
+// CheckResult is the result of a single HealthCheck.
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// healthHandler will run the checks and serve a HealthReport, reusing the last
+// report until the TTL has passed. Concurrent probes share a single run of the
+// checks.
+type healthHandler struct {
+	checks []HealthCheck
+	ready  bool
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	report  HealthReport
+	expires time.Time
+	running *healthRun
+}
+
+// healthRun is a run of the checks that probes can wait on.
+type healthRun struct {
+	done   chan struct{}
+	report HealthReport
+}
+
+// detachedContext keeps the values of a request context without its deadline or
+// cancellation, so a probe that goes away does not fail the checks it started.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
+
+func newHealthHandler(checks []HealthCheck, ready bool, ttl time.Duration) *healthHandler {
+	if !ready {
+		var live []HealthCheck
+		for _, c := range checks {
+			if c.Liveness {
+				live = append(live, c)
+			}
+		}
+		checks = live
+	}
+	if ttl == 0 {
+		ttl = DefaultHealthCacheTTL
+	}
+	return &healthHandler{checks: checks, ready: ready, ttl: ttl}
+}
+
+func (h *healthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := HealthReport{Status: "stopping"}
+	if !h.ready || !Stopping(r.Context()) {
+		report = h.run(r.Context())
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	EncodeJSONResponse(r.Context(), w, report)
+}
+
+// run returns the cached report or waits for a run of the checks if it has
+// expired. The checks run on a detached context, so they are not cut short by
+// the probe's request.
+func (h *healthHandler) run(ctx context.Context) HealthReport {
+	h.mu.Lock()
+	if time.Now().Before(h.expires) {
+		report := h.report
+		h.mu.Unlock()
+		return report
+	}
+	run := h.running
+	if run == nil {
+		run = &healthRun{done: make(chan struct{})}
+		h.running = run
+		go h.check(detachedContext{ctx}, run)
+	}
+	h.mu.Unlock()
+
+	select {
+	case <-run.done:
+		return run.report
+	case <-ctx.Done():
+		return HealthReport{Status: "failing"}
+	}
+}
+
+// check will run every check concurrently and cache the report unless one of
+// them was cancelled.
+func (h *healthHandler) check(ctx context.Context, run *healthRun) {
+	results := make([]CheckResult, len(h.checks))
+	cancelled := make([]bool, len(h.checks))
+	var wg sync.WaitGroup
+	for i, c := range h.checks {
+		wg.Add(1)
+		go func(i int, c HealthCheck) {
+			defer wg.Done()
+			var err error
+			results[i], err = runCheck(ctx, c)
+			cancelled[i] = errors.Cause(err) == context.Canceled
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := HealthReport{Status: "ok", Checks: make(map[string]CheckResult, len(results))}
+	cache := true
+	for i, res := range results {
+		if res.Status != "ok" {
+			report.Status = "failing"
+		}
+		report.Checks[h.checks[i].Name] = res
+		cache = cache && !cancelled[i]
+	}
+
+	h.mu.Lock()
+	if cache {
+		h.report, h.expires = report, time.Now().Add(h.ttl)
+	}
+	h.running = nil
+	h.mu.Unlock()
+
+	run.report = report
+	close(run.done)
+}
+
+// runCheck will run a single check within its timeout. It returns the check's
+// error along with its result.
+func runCheck(ctx context.Context, c HealthCheck) (CheckResult, error) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHealthCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	errs := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				errs <- fmt.Errorf("check panicked: %v", p)
+			}
+		}()
+		errs <- c.Check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	res := CheckResult{
+		Status:    "ok",
+		LatencyMS: float64(time.Since(start)) / float64(time.Millisecond),
+	}
+	if err != nil {
+		res.Status, res.Error = "failing", err.Error()
+	}
+	return res, err
+}
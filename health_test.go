@@ -0,0 +1,113 @@
+package marvin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		check     func(ctx context.Context, call int32) error
+		cancelled bool
+
+		wantCodes []int
+		wantCalls int32
+	}{
+		{
+			name:      "ok is cached",
+			check:     func(context.Context, int32) error { return nil },
+			wantCodes: []int{http.StatusOK, http.StatusOK},
+			wantCalls: 1,
+		},
+		{
+			name:      "failing is cached",
+			check:     func(context.Context, int32) error { return errors.New("no db") },
+			wantCodes: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+			wantCalls: 1,
+		},
+		{
+			name: "cancelled is not cached",
+			check: func(_ context.Context, call int32) error {
+				if call == 1 {
+					return context.Canceled
+				}
+				return nil
+			},
+			wantCodes: []int{http.StatusServiceUnavailable, http.StatusOK},
+			wantCalls: 2,
+		},
+		{
+			name:      "checks outlive the probe",
+			check:     func(ctx context.Context, _ int32) error { return ctx.Err() },
+			cancelled: true,
+			wantCodes: []int{http.StatusOK, http.StatusOK},
+			wantCalls: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls int32
+			h := newHealthHandler([]HealthCheck{{
+				Name: "db",
+				Check: func(ctx context.Context) error {
+					return test.check(ctx, atomic.AddInt32(&calls, 1))
+				},
+			}}, true, time.Minute)
+			for i, want := range test.wantCodes {
+				r := httptest.NewRequest(http.MethodGet, DefaultReadyPath, nil)
+				if test.cancelled && i == 0 {
+					// the probe goes away before the checks complete
+					ctx, cancel := context.WithCancel(r.Context())
+					cancel()
+					h.run(ctx)
+					continue
+				}
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+				if w.Code != want {
+					t.Errorf("expected probe %d to get %d, got %d", i+1, want, w.Code)
+				}
+			}
+			if got := atomic.LoadInt32(&calls); got != test.wantCalls {
+				t.Errorf("expected %d check calls, got %d", test.wantCalls, got)
+			}
+		})
+	}
+}
+
+func TestHealthHandlerSharesRuns(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	h := newHealthHandler([]HealthCheck{{
+		Name: "db",
+		Check: func(context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return nil
+		},
+	}}, true, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if report := h.run(context.Background()); report.Status != "ok" {
+				t.Errorf("expected an ok report, got %q", report.Status)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("expected the probes to share a run, got %d calls", calls)
+	}
+}
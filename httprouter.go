@@ -0,0 +1,139 @@
+package marvin
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+)
+
+// HTTPRouter is a Router implementation for julienschmidt's httprouter.
+//
+// Gorilla style path templates are translated so services can switch routers
+// without rewriting their endpoint maps: `/cats/{id}` becomes `/cats/:id` and a
+// trailing `{path:.*}` becomes the catch-all `*path`. Variables with patterns, like
+// `{id:[0-9]+}`, are matched with httprouter and then checked against their
+// pattern, responding with the not found handler if they do not match.
+//
+// httprouter only supports variables that span a whole path segment and does not
+// allow a variable to conflict with a literal segment, like `/cats/{id}` and
+// `/cats/new`. Handle will panic for such paths, as httprouter does, and
+// NewServerE will report them in its *RegistrationError.
+type HTTPRouter struct {
+	mux       *httprouter.Router
+	notFound  http.Handler
+	unmatched func(http.ResponseWriter, *http.Request) bool
+}
+
+// NewHTTPRouter returns an HTTPRouter with httprouter's default settings.
+func NewHTTPRouter() *HTTPRouter {
+	h := &HTTPRouter{mux: httprouter.New()}
+	h.mux.NotFound = http.HandlerFunc(h.handleNotFound)
+	return h
+}
+
+// Handle will call the httprouter Handler() method with the translated path.
+func (h *HTTPRouter) Handle(method, path string, handler http.Handler) {
+	route, catchAll, patterns, err := httpRouterPath(path)
+	if err != nil {
+		panic(err.Error())
+	}
+	h.mux.Handle(method, route, func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		// copy the route params into a shared location
+		vars := make(map[string]string, len(ps))
+		for _, p := range ps {
+			val := p.Value
+			if p.Key == catchAll {
+				// catch-all values include their leading slash
+				val = strings.TrimPrefix(val, "/")
+			}
+			if re, ok := patterns[p.Key]; ok && !re.MatchString(val) {
+				h.handleNotFound(w, r)
+				return
+			}
+			vars[p.Key] = val
+		}
+		handler.ServeHTTP(w, SetRouteVars(r, vars))
+	})
+}
+
+// HandleFunc will call the httprouter Handler() method with the translated path.
+func (h *HTTPRouter) HandleFunc(method, path string, handler func(http.ResponseWriter, *http.Request)) {
+	h.Handle(method, path, http.HandlerFunc(handler))
+}
+
+// SetNotFoundHandler will set the handler httprouter.Router.NotFound calls.
+func (h *HTTPRouter) SetNotFoundHandler(handler http.Handler) {
+	h.notFound = handler
+}
+
+// ServeHTTP will call httprouter.ServerHTTP directly.
+func (h *HTTPRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *HTTPRouter) handleNotFound(w http.ResponseWriter, r *http.Request) {
+	if h.unmatched != nil && h.unmatched(w, r) {
+		return
+	}
+	if h.notFound != nil {
+		h.notFound.ServeHTTP(w, r)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// setUnmatched will also make httprouter send OPTIONS requests and requests
+// with an unregistered method to the not found handler, so the Server can
+// answer them.
+func (h *HTTPRouter) setUnmatched(f func(http.ResponseWriter, *http.Request) bool) {
+	h.unmatched = f
+	h.mux.HandleOPTIONS = false
+	h.mux.HandleMethodNotAllowed = false
+}
+
+// catchAllPatterns are the Gorilla patterns that match the rest of a path.
+var catchAllPatterns = map[string]bool{".*": true, ".+": true}
+
+// httpRouterPath will translate a Gorilla style path template into an httprouter
+// path. It also returns the name of the catch-all variable, if there is one, and
+// the compiled patterns of any variables that have them.
+func httpRouterPath(tmpl string) (path, catchAll string, patterns map[string]*regexp.Regexp, err error) {
+	parts, err := parsePath(tmpl)
+	if err != nil {
+		return "", "", nil, err
+	}
+	patterns = map[string]*regexp.Regexp{}
+	for i, p := range parts {
+		if !p.isVar() {
+			path += p.literal
+			continue
+		}
+		if !strings.HasSuffix(path, "/") {
+			return "", "", nil, errors.Errorf("httprouter: variable %q must start a path segment in %q", p.name, tmpl)
+		}
+		last := i == len(parts)-1
+		if last && catchAllPatterns[p.pattern] {
+			path += "*" + p.name
+			catchAll = p.name
+			if p.pattern == ".+" {
+				patterns[p.name] = regexp.MustCompile(`^.+$`)
+			}
+			continue
+		}
+		if !last && !strings.HasPrefix(parts[i+1].literal, "/") {
+			return "", "", nil, errors.Errorf("httprouter: variable %q must end a path segment in %q", p.name, tmpl)
+		}
+		path += ":" + p.name
+		if p.pattern != "" {
+			re, err := regexp.Compile("^(?:" + p.pattern + ")$")
+			if err != nil {
+				return "", "", nil, errors.Wrapf(err, "httprouter: invalid pattern for variable %q in %q", p.name, tmpl)
+			}
+			patterns[p.name] = re
+		}
+	}
+	return path, catchAll, patterns, nil
+}
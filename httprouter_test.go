@@ -0,0 +1,121 @@
+package marvin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// varsHandler responds with the route variables of the request in sorted order.
+var varsHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	var vars []string
+	for k, v := range Vars(r) {
+		vars = append(vars, k+"="+v)
+	}
+	sort.Strings(vars)
+	fmt.Fprint(w, strings.Join(vars, "&"))
+})
+
+func TestHTTPRouter(t *testing.T) {
+	router := NewHTTPRouter()
+	router.SetNotFoundHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	router.Handle(http.MethodGet, "/cats", varsHandler)
+	router.Handle(http.MethodGet, "/cats/{id:[0-9]+}", varsHandler)
+	router.Handle(http.MethodGet, "/cats/{id:[0-9]+}/toys/{toy}", varsHandler)
+	router.Handle(http.MethodGet, "/files/{path:.*}", varsHandler)
+	router.Handle(http.MethodGet, "/dirs/{path:.+}", varsHandler)
+
+	tests := []struct {
+		name string
+		path string
+
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "literal",
+			path:     "/cats",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "pattern",
+			path:     "/cats/123",
+			wantCode: http.StatusOK,
+			wantBody: "id=123",
+		},
+		{
+			name:     "pattern mismatch",
+			path:     "/cats/tom",
+			wantCode: http.StatusTeapot,
+		},
+		{
+			name:     "several vars",
+			path:     "/cats/123/toys/mouse",
+			wantCode: http.StatusOK,
+			wantBody: "id=123&toy=mouse",
+		},
+		{
+			name:     "catch-all",
+			path:     "/files/cats/tom.jpg",
+			wantCode: http.StatusOK,
+			wantBody: "path=cats/tom.jpg",
+		},
+		{
+			name:     "empty catch-all",
+			path:     "/files/",
+			wantCode: http.StatusOK,
+			wantBody: "path=",
+		},
+		{
+			name:     "empty required catch-all",
+			path:     "/dirs/",
+			wantCode: http.StatusTeapot,
+		},
+		{
+			name:     "not found",
+			path:     "/dogs",
+			wantCode: http.StatusTeapot,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+			if w.Code != test.wantCode {
+				t.Fatalf("expected status %d, got %d", test.wantCode, w.Code)
+			}
+			if w.Code == http.StatusOK && w.Body.String() != test.wantBody {
+				t.Errorf("expected vars %q, got %q", test.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHTTPRouterInvalidPath(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{name: "partial segment", path: "/cats/{id}.json"},
+		{name: "segment prefix", path: "/cats/cat-{id}"},
+		{name: "invalid pattern", path: "/cats/{id:[}"},
+		{name: "conflicting literal", path: "/cats/new"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := NewHTTPRouter()
+			router.Handle(http.MethodGet, "/cats/{id}", varsHandler)
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected Handle to panic for %q", test.path)
+				}
+			}()
+			router.Handle(http.MethodGet, test.path, varsHandler)
+		})
+	}
+}
@@ -7,6 +7,29 @@ import (
 	"google.golang.org/appengine"
 )
 
-var NewContext = func(r *http.Request) context.Context {
-	return appengine.NewContext(r)
+type contextKey int
+
+const appEngineKey contextKey = 0
+
+// NewContext builds the base context for the requests of Servers that were not
+// given a ContextProvider.
+var NewContext = AppEngineContext
+
+// AppEngineContext returns the App Engine context for the request and marks it so
+// IsAppEngine can tell it apart from other contexts.
+func AppEngineContext(r *http.Request) context.Context {
+	return WithAppEngine(appengine.NewContext(r))
+}
+
+// WithAppEngine will mark an App Engine context, like one from aetest, so
+// IsAppEngine will return true for it.
+func WithAppEngine(ctx context.Context) context.Context {
+	return context.WithValue(ctx, appEngineKey, true)
+}
+
+// IsAppEngine returns true if the context was made by AppEngineContext and can be
+// used with the App Engine APIs.
+func IsAppEngine(ctx context.Context) bool {
+	ok, _ := ctx.Value(appEngineKey).(bool)
+	return ok
 }
@@ -0,0 +1,118 @@
+package marvin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// JSONCodec serializes values as JSON. Values that implement proto.Message are
+// serialized with the canonical proto3 JSON mapping so oneofs, enums and
+// well-known types match what Protobuf JSON clients expect. All other values,
+// and values that implement json.Marshaler, use encoding/json.
+type JSONCodec struct {
+	// EmitDefaults will include fields with zero values in the output.
+	EmitDefaults bool
+	// OrigName will use the original field names from the .proto file instead
+	// of their lowerCamelCase JSON names. Both are always accepted when decoding.
+	OrigName bool
+	// DisallowUnknownFields will reject messages with fields that are not
+	// declared in the .proto file.
+	DisallowUnknownFields bool
+}
+
+// DefaultJSONCodec is used by JSON endpoints, negotiated endpoints, Twirp and the
+// JSON forms of ProtoStatusResponse and JSONStatusResponse. Set it before the
+// server is initialized to change how every JSON payload is serialized.
+var DefaultJSONCodec JSONCodec
+
+// Marshal will serialize v as JSON.
+func (c JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	if _, ok := v.(json.Marshaler); ok {
+		return json.Marshal(v)
+	}
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return json.Marshal(v)
+	}
+	var buf bytes.Buffer
+	m := jsonpb.Marshaler{EmitDefaults: c.EmitDefaults, OrigName: c.OrigName}
+	if err := m.Marshal(&buf, msg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal will parse the JSON in b into v.
+func (c JSONCodec) Unmarshal(b []byte, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return json.Unmarshal(b, v)
+	}
+	u := jsonpb.Unmarshaler{AllowUnknownFields: !c.DisallowUnknownFields}
+	return u.Unmarshal(bytes.NewReader(b), msg)
+}
+
+// EncodeResponse is an httptransport.EncodeResponseFunc that serializes the response
+// with the codec. Like httptransport.EncodeJSONResponse, it respects responses that
+// implement httptransport.Headerer and httptransport.StatusCoder.
+func (c JSONCodec) EncodeResponse(_ context.Context, w http.ResponseWriter, res interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if headerer, ok := res.(httptransport.Headerer); ok {
+		for k := range headerer.Headers() {
+			w.Header().Set(k, headerer.Headers().Get(k))
+		}
+	}
+	code := http.StatusOK
+	if sc, ok := res.(httptransport.StatusCoder); ok {
+		code = sc.StatusCode()
+	}
+	if code == http.StatusNoContent {
+		w.WriteHeader(code)
+		return nil
+	}
+	b, err := c.Marshal(res)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(code)
+	_, err = w.Write(b)
+	return err
+}
+
+// EncodeRequest is an httptransport.EncodeRequestFunc that serializes the request
+// with the codec.
+func (c JSONCodec) EncodeRequest(_ context.Context, r *http.Request, req interface{}) error {
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if headerer, ok := req.(httptransport.Headerer); ok {
+		for k := range headerer.Headers() {
+			r.Header.Set(k, headerer.Headers().Get(k))
+		}
+	}
+	b, err := c.Marshal(req)
+	if err != nil {
+		return err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	r.ContentLength = int64(len(b))
+	return nil
+}
+
+// EncodeJSONResponse is an httptransport.EncodeResponseFunc that serializes the
+// response with the DefaultJSONCodec. This is the default encoder for JSONEndpoints.
+func EncodeJSONResponse(ctx context.Context, w http.ResponseWriter, res interface{}) error {
+	return DefaultJSONCodec.EncodeResponse(ctx, w, res)
+}
+
+// EncodeJSONRequest is an httptransport.EncodeRequestFunc that serializes the
+// request with the DefaultJSONCodec. This is the default client encoder for
+// JSON endpoints.
+func EncodeJSONRequest(ctx context.Context, r *http.Request, req interface{}) error {
+	return DefaultJSONCodec.EncodeRequest(ctx, r, req)
+}
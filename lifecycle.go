@@ -0,0 +1,132 @@
+package marvin
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/NYTimes/marvin/internal"
+)
+
+const (
+	startURI = "/_ah/start"
+	stopURI  = "/_ah/stop"
+)
+
+// DefaultStopTimeout is the time a Stopper has to finish unless the StopTimeout
+// option is used. App Engine will shut an instance down 30 seconds after sending
+// the stop request.
+const DefaultStopTimeout = 25 * time.Second
+
+// Starter can be implemented by a Service to run when App Engine sends a start
+// request to a new manual or basic scaling instance. With manual scaling, Start
+// may block to run background work for as long as the instance is up. If Start
+// returns an error, the start request will respond with a 500 and App Engine will
+// shut the instance down.
+//
+// Like the stop request, the start request is only served for Servers started
+// with Init and is refused with a 403 unless App Engine sent it. If a service
+// registers its own /_ah/start endpoint, the Starter is ignored.
+type Starter interface {
+	Start(ctx context.Context) error
+}
+
+// Stopper can be implemented by a Service to drain background work when App Engine
+// sends a stop request to a manual or basic scaling instance. The context given to
+// Stop will be done once the stop timeout has passed. Stopping will return true as
+// soon as the stop request is received and the Server is shut down once Stop
+// returns.
+//
+// The stop request is served for every Server started with Init, so services
+// without a Stopper still run their OnShutdown hooks, and is refused with a 403
+// unless App Engine sent it. If a service registers its own /_ah/stop endpoint,
+// the Stopper and the hooks are not run by marvin.
+type Stopper interface {
+	Stop(ctx context.Context) error
+}
+
+// StopTimeout will set the time a Stopper has to finish.
+func StopTimeout(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.stopTimeout = d
+	}
+}
+
+// Stopping returns true once the Server serving the request has been asked to
+// stop. Endpoints can use it with their request context to avoid starting new
+// work.
+func Stopping(ctx context.Context) bool {
+	st, _ := ctx.Value(shutdownKey).(*shutdownState)
+	return st.stopped()
+}
+
+// Stopping returns true once the Server has been asked to stop. Background
+// workers can use it to avoid starting new work.
+func (s Server) Stopping() bool {
+	return s.shutdown.stopped()
+}
+
+// startHandler returns the handler for /_ah/start that will run the Starter.
+// Requests App Engine did not send are refused.
+func startHandler(st Starter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !fromAppEngine(r) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		if err := st.Start(r.Context()); err != nil {
+			logErrorf(r.Context(), "unable to start instance: %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// appEngineLifecycle is the server option Init uses to serve App Engine's start
+// and stop requests.
+func appEngineLifecycle(s *Server) {
+	s.lifecycle = true
+}
+
+// fromAppEngine returns true if App Engine itself sent the request. App Engine
+// strips any X-Appengine headers a client sends and adds X-Appengine-Country to
+// every request from outside of it, so only requests with an App Engine context,
+// no country and no inbound app ID are trusted.
+func fromAppEngine(r *http.Request) bool {
+	return internal.IsAppEngine(r.Context()) &&
+		r.Header.Get("X-Appengine-Country") == "" &&
+		r.Header.Get("X-Appengine-Inbound-Appid") == ""
+}
+
+// stopHandler returns the handler for /_ah/stop that will flag the instance as
+// stopping, run the Stopper, if there is one, and shut the Server down within the
+// stop timeout. Requests App Engine did not send are refused.
+func (s Server) stopHandler(st Stopper) http.Handler {
+	timeout := s.stopTimeout
+	if timeout <= 0 {
+		timeout = DefaultStopTimeout
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !fromAppEngine(r) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		s.shutdown.stop()
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		code := http.StatusOK
+		if st != nil {
+			if err := st.Stop(ctx); err != nil {
+				logErrorf(r.Context(), "unable to stop instance cleanly: %s", err)
+				code = http.StatusInternalServerError
+			}
+		}
+		if err := s.Shutdown(ctx); err != nil {
+			logErrorf(r.Context(), "unable to shut down server cleanly: %s", err)
+			code = http.StatusInternalServerError
+		}
+		w.WriteHeader(code)
+	})
+}
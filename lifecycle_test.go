@@ -0,0 +1,200 @@
+package marvin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/NYTimes/marvin/internal"
+)
+
+type stopService struct {
+	testService
+	stopped *bool
+}
+
+func (s stopService) Stop(context.Context) error {
+	*s.stopped = true
+	return nil
+}
+
+func TestStopHandler(t *testing.T) {
+	appEngine := BaseContext(func(r *http.Request) context.Context {
+		return internal.WithAppEngine(r.Context())
+	})
+	tests := []struct {
+		name string
+		opts []ServerOption
+		hdrs []string
+
+		wantCode    int
+		wantStopped bool
+	}{
+		{
+			name:     "not from Init",
+			opts:     []ServerOption{appEngine},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "not App Engine",
+			opts:     []ServerOption{appEngineLifecycle},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "external request",
+			opts:     []ServerOption{appEngine, appEngineLifecycle},
+			hdrs:     []string{"X-Appengine-Country", "US"},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "other app",
+			opts:     []ServerOption{appEngine, appEngineLifecycle},
+			hdrs:     []string{"X-Appengine-Inbound-Appid", "other-app"},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:        "App Engine",
+			opts:        []ServerOption{appEngine, appEngineLifecycle},
+			wantCode:    http.StatusOK,
+			wantStopped: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stopped bool
+			svr := newTestServer(t, stopService{
+				testService: testService{endpoints: map[string]map[string]HTTPEndpoint{
+					"/cat": {"GET": testEndpoint("cat")},
+				}},
+				stopped: &stopped,
+			}, test.opts...)
+			w := serve(svr, http.MethodGet, stopURI, "", test.hdrs...)
+			if w.Code != test.wantCode {
+				t.Errorf("expected status %d, got %d", test.wantCode, w.Code)
+			}
+			if stopped != test.wantStopped {
+				t.Errorf("expected stopped to be %t", test.wantStopped)
+			}
+		})
+	}
+}
+
+type startService struct {
+	testService
+	started *bool
+}
+
+func (s startService) Start(context.Context) error {
+	*s.started = true
+	return nil
+}
+
+func TestStartHandler(t *testing.T) {
+	appEngine := BaseContext(func(r *http.Request) context.Context {
+		return internal.WithAppEngine(r.Context())
+	})
+	tests := []struct {
+		name string
+		opts []ServerOption
+		hdrs []string
+
+		wantCode    int
+		wantStarted bool
+	}{
+		{
+			name:     "not from Init",
+			opts:     []ServerOption{appEngine},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "not App Engine",
+			opts:     []ServerOption{appEngineLifecycle},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "external request",
+			opts:     []ServerOption{appEngine, appEngineLifecycle},
+			hdrs:     []string{"X-Appengine-Country", "US"},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:        "App Engine",
+			opts:        []ServerOption{appEngine, appEngineLifecycle},
+			wantCode:    http.StatusOK,
+			wantStarted: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var started bool
+			svr := newTestServer(t, startService{
+				testService: testService{endpoints: map[string]map[string]HTTPEndpoint{
+					"/cat": {"GET": testEndpoint("cat")},
+				}},
+				started: &started,
+			}, test.opts...)
+			w := serve(svr, http.MethodGet, startURI, "", test.hdrs...)
+			if w.Code != test.wantCode {
+				t.Errorf("expected status %d, got %d", test.wantCode, w.Code)
+			}
+			if started != test.wantStarted {
+				t.Errorf("expected started to be %t", test.wantStarted)
+			}
+		})
+	}
+}
+
+func TestFromAppEngine(t *testing.T) {
+	tests := []struct {
+		name      string
+		appEngine bool
+		hdrs      []string
+
+		want bool
+	}{
+		{name: "App Engine", appEngine: true, want: true},
+		{name: "not App Engine", appEngine: false},
+		{name: "external request", appEngine: true, hdrs: []string{"X-Appengine-Country", "US"}},
+		{name: "other app", appEngine: true, hdrs: []string{"X-Appengine-Inbound-Appid", "other-app"}},
+		{
+			// external requests claiming to be cron still get the country App Engine adds
+			name:      "spoofed cron request",
+			appEngine: true,
+			hdrs:      []string{"X-Appengine-Cron", "true", "X-Appengine-Country", "ZZ"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, stopURI, nil)
+			for i := 0; i+1 < len(test.hdrs); i += 2 {
+				r.Header.Set(test.hdrs[i], test.hdrs[i+1])
+			}
+			if test.appEngine {
+				r = r.WithContext(internal.WithAppEngine(r.Context()))
+			}
+			if got := fromAppEngine(r); got != test.want {
+				t.Errorf("expected %t, got %t", test.want, got)
+			}
+		})
+	}
+}
+
+func TestStopHandlerWithoutStopper(t *testing.T) {
+	appEngine := BaseContext(func(r *http.Request) context.Context {
+		return internal.WithAppEngine(r.Context())
+	})
+	var hooked bool
+	svr := newTestServer(t, testService{endpoints: map[string]map[string]HTTPEndpoint{
+		"/cat": {"GET": testEndpoint("cat")},
+	}}, appEngine, appEngineLifecycle, OnShutdown(func(context.Context) error {
+		hooked = true
+		return nil
+	}))
+	w := serve(svr, http.MethodGet, stopURI, "")
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if !hooked || !svr.Stopping() {
+		t.Error("expected the server to be shut down with its hooks")
+	}
+}
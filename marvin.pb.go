@@ -0,0 +1,155 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: marvin.proto
+
+/*
+Package marvin is a generated protocol buffer package.
+
+It is generated from these files:
+
+	marvin.proto
+
+It has these top-level messages:
+
+	ErrorMessage
+	Problem
+*/
+package marvin
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+// ErrorMessage is a simple message that marvin uses to describe errors it
+// generates on behalf of a service. It is wire compatible with any message that
+// has a string 'message' as its first field and serializes to JSON as
+// `{"message": "...", "code": "...", "details": {...}}`.
+type ErrorMessage struct {
+	Message string `protobuf:"bytes,1,opt,name=message" json:"message,omitempty"`
+	// The canonical error code, like "not_found".
+	Code    string            `protobuf:"bytes,2,opt,name=code" json:"code,omitempty"`
+	Details map[string]string `protobuf:"bytes,3,rep,name=details" json:"details,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *ErrorMessage) Reset()                    { *m = ErrorMessage{} }
+func (m *ErrorMessage) String() string            { return proto.CompactTextString(m) }
+func (*ErrorMessage) ProtoMessage()               {}
+func (*ErrorMessage) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+func (m *ErrorMessage) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *ErrorMessage) GetCode() string {
+	if m != nil {
+		return m.Code
+	}
+	return ""
+}
+
+func (m *ErrorMessage) GetDetails() map[string]string {
+	if m != nil {
+		return m.Details
+	}
+	return nil
+}
+
+// Problem is the Protobuf equivalent of an RFC 7807 problem document.
+type Problem struct {
+	Type  string `protobuf:"bytes,1,opt,name=type" json:"type,omitempty"`
+	Title string `protobuf:"bytes,2,opt,name=title" json:"title,omitempty"`
+	// The HTTP status code.
+	Status     int32             `protobuf:"varint,3,opt,name=status" json:"status,omitempty"`
+	Detail     string            `protobuf:"bytes,4,opt,name=detail" json:"detail,omitempty"`
+	Instance   string            `protobuf:"bytes,5,opt,name=instance" json:"instance,omitempty"`
+	Extensions map[string]string `protobuf:"bytes,6,rep,name=extensions" json:"extensions,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *Problem) Reset()                    { *m = Problem{} }
+func (m *Problem) String() string            { return proto.CompactTextString(m) }
+func (*Problem) ProtoMessage()               {}
+func (*Problem) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *Problem) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *Problem) GetTitle() string {
+	if m != nil {
+		return m.Title
+	}
+	return ""
+}
+
+func (m *Problem) GetStatus() int32 {
+	if m != nil {
+		return m.Status
+	}
+	return 0
+}
+
+func (m *Problem) GetDetail() string {
+	if m != nil {
+		return m.Detail
+	}
+	return ""
+}
+
+func (m *Problem) GetInstance() string {
+	if m != nil {
+		return m.Instance
+	}
+	return ""
+}
+
+func (m *Problem) GetExtensions() map[string]string {
+	if m != nil {
+		return m.Extensions
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*ErrorMessage)(nil), "marvin.ErrorMessage")
+	proto.RegisterType((*Problem)(nil), "marvin.Problem")
+}
+
+func init() { proto.RegisterFile("marvin.proto", fileDescriptor0) }
+
+var fileDescriptor0 = []byte{
+	// 273 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x91, 0x4f, 0x4b, 0xc3, 0x40,
+	0x10, 0xc5, 0xd9, 0xa6, 0x49, 0xea, 0x18, 0x50, 0x16, 0x91, 0x25, 0x17, 0x6b, 0x4f, 0x39, 0xe5,
+	0xa0, 0x17, 0xa9, 0x88, 0x20, 0xe6, 0x28, 0x48, 0x8e, 0xde, 0xb6, 0xed, 0x22, 0xc1, 0x64, 0xb7,
+	0xec, 0x4e, 0x8b, 0xf9, 0x60, 0x7e, 0x3e, 0x25, 0xfb, 0x27, 0x04, 0x6f, 0xbd, 0xbd, 0xdf, 0xce,
+	0xbc, 0xcc, 0x9b, 0x0c, 0x64, 0x1d, 0xd7, 0xc7, 0x46, 0x96, 0x7b, 0xad, 0x50, 0xd1, 0xc4, 0xd1,
+	0xea, 0x87, 0x40, 0x56, 0x69, 0xad, 0xf4, 0x9b, 0x30, 0x86, 0x7f, 0x0a, 0xca, 0x20, 0xed, 0x9c,
+	0x64, 0x64, 0x49, 0x8a, 0xb3, 0x3a, 0x20, 0xa5, 0x30, 0xdf, 0xaa, 0x9d, 0x60, 0x33, 0xfb, 0x6c,
+	0x35, 0x7d, 0x84, 0x74, 0x27, 0x90, 0x37, 0xad, 0x61, 0xd1, 0x32, 0x2a, 0xce, 0xef, 0x6e, 0x4b,
+	0x3f, 0x66, 0xfa, 0xd1, 0xf2, 0xd5, 0xf5, 0x54, 0x12, 0x75, 0x5f, 0x07, 0x47, 0xbe, 0x86, 0x6c,
+	0x5a, 0xa0, 0x97, 0x10, 0x7d, 0x89, 0xde, 0x8f, 0x1d, 0x24, 0xbd, 0x82, 0xf8, 0xc8, 0xdb, 0x43,
+	0x98, 0xe9, 0x60, 0x3d, 0x7b, 0x20, 0xab, 0x5f, 0x02, 0xe9, 0xbb, 0x56, 0x9b, 0x56, 0x74, 0x43,
+	0x30, 0xec, 0xf7, 0x21, 0xaf, 0xd5, 0x83, 0x13, 0x1b, 0x6c, 0x47, 0xa7, 0x05, 0x7a, 0x0d, 0x89,
+	0x41, 0x8e, 0x87, 0x21, 0x2d, 0x29, 0xe2, 0xda, 0xd3, 0xf0, 0xee, 0x42, 0xb1, 0xb9, 0x6d, 0xf7,
+	0x44, 0x73, 0x58, 0x34, 0xd2, 0x20, 0x97, 0x5b, 0xc1, 0x62, 0x5b, 0x19, 0x99, 0x3e, 0x03, 0x88,
+	0x6f, 0x14, 0xd2, 0x34, 0x4a, 0x1a, 0x96, 0xd8, 0xed, 0x6f, 0xc2, 0xf6, 0x3e, 0x5a, 0x59, 0x8d,
+	0x1d, 0x6e, 0xf7, 0x89, 0x25, 0x7f, 0x82, 0x8b, 0x7f, 0xe5, 0x53, 0xfe, 0xc0, 0xcb, 0xe2, 0xc3,
+	0xdf, 0x70, 0x93, 0xd8, 0x93, 0xde, 0xff, 0x0d, 0x00, 0x82, 0x62, 0xfe, 0xc8, 0xe2, 0x01, 0x00,
+	0x00,
+}
@@ -7,6 +7,7 @@ import (
 
 	"google.golang.org/appengine/aetest"
 
+	"github.com/NYTimes/marvin"
 	"github.com/NYTimes/marvin/internal"
 )
 
@@ -29,6 +30,7 @@ func SetupTestContextWithContext(t *testing.T) (context.Context, func()) {
 	if err != nil {
 		t.Fatalf("unable to init aetest context: %s", err)
 	}
+	ctx = internal.WithAppEngine(ctx)
 	internal.NewContext = func(r *http.Request) context.Context {
 		return ctx
 	}
@@ -44,6 +46,7 @@ func SetupBenchmarkContext(t *testing.B) func() {
 	if err != nil {
 		t.Fatalf("unable to init aetest context: %s", err)
 	}
+	ctx = internal.WithAppEngine(ctx)
 	internal.NewContext = func(r *http.Request) context.Context {
 		return ctx
 	}
@@ -51,9 +54,39 @@ func SetupBenchmarkContext(t *testing.B) func() {
 }
 
 // SetServerContext will override the server context and inject the given context
-// into incoming requests. The effect of this function is global.
+// into incoming requests. The effect of this function is global and does not apply
+// to Servers given the marvin.BaseContext option, so prefer ServerContext in new tests.
+// The context is treated as an App Engine context, like the ones from aetest.
 func SetServerContext(ctx context.Context) {
+	ctx = internal.WithAppEngine(ctx)
 	internal.NewContext = func(r *http.Request) context.Context {
 		return ctx
 	}
 }
+
+// ServerContext returns a server option that will inject the given context into the
+// incoming requests of a single Server. Unlike SetServerContext, it does not change
+// any global state, so tests using it can run in parallel:
+//
+//	svr := marvin.NewServer(svc, marvintest.ServerContext(ctx))
+//
+// The context is treated as an App Engine context, like the ones from aetest.
+func ServerContext(ctx context.Context) marvin.ServerOption {
+	ctx = internal.WithAppEngine(ctx)
+	return marvin.BaseContext(func(*http.Request) context.Context {
+		return ctx
+	})
+}
+
+// NewTestContext will start up dev_appserver.py via `appengine/aetest` and return a
+// server option that injects its context into the requests of a single Server, along
+// with the context itself for use outside the server.
+// This call is very expensive and should be used sparingly in your test suite.
+func NewTestContext(t testing.TB) (marvin.ServerOption, context.Context, func()) {
+	ctx, done, err := aetest.NewContext()
+	if err != nil {
+		t.Fatalf("unable to init aetest context: %s", err)
+	}
+	ctx = internal.WithAppEngine(ctx)
+	return ServerContext(ctx), ctx, done
+}
@@ -0,0 +1,84 @@
+package marvintest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+
+	"github.com/NYTimes/marvin"
+)
+
+type contextKey int
+
+// nameService responds with the name in the request's context.
+type nameService struct{}
+
+func (nameService) HTTPMiddleware(h http.Handler) http.Handler { return h }
+
+func (nameService) Middleware(ep endpoint.Endpoint) endpoint.Endpoint { return ep }
+
+func (nameService) Options() []httptransport.ServerOption { return nil }
+
+func (nameService) RouterOptions() []marvin.RouterOption { return nil }
+
+func (nameService) JSONEndpoints() map[string]map[string]marvin.HTTPEndpoint {
+	return map[string]map[string]marvin.HTTPEndpoint{
+		"/name": {"GET": {Endpoint: func(ctx context.Context, _ interface{}) (interface{}, error) {
+			name, _ := ctx.Value(contextKey(0)).(string)
+			return name, nil
+		}}},
+	}
+}
+
+func TestServerContext(t *testing.T) {
+	for _, name := range []string{"tom", "felix"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.WithValue(context.Background(), contextKey(0), name)
+			svr := marvin.NewServer(nameService{}, ServerContext(ctx))
+			w := httptest.NewRecorder()
+			svr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/name", nil))
+			if got := strings.TrimSpace(w.Body.String()); got != `"`+name+`"` {
+				t.Errorf("expected name %q, got %s", name, got)
+			}
+		})
+	}
+}
+
+func TestServerContextInternal(t *testing.T) {
+	tests := []struct {
+		name  string
+		appID string
+
+		wantCode int
+	}{
+		{name: "allowed app", appID: "cats-app", wantCode: http.StatusOK},
+		{name: "no app ID", wantCode: http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svr := marvin.NewServer(internalService{}, ServerContext(context.Background()))
+			r := httptest.NewRequest(http.MethodGet, "/name", nil)
+			if test.appID != "" {
+				r.Header.Set("X-Appengine-Inbound-Appid", test.appID)
+			}
+			w := httptest.NewRecorder()
+			svr.ServeHTTP(w, r)
+			if w.Code != test.wantCode {
+				t.Errorf("expected status %d, got %d: %s", test.wantCode, w.Code, w.Body)
+			}
+		})
+	}
+}
+
+// internalService is a nameService that only allows requests from cats-app.
+type internalService struct{ nameService }
+
+func (internalService) Middleware(ep endpoint.Endpoint) endpoint.Endpoint {
+	return marvin.InternalFrom("cats-app")(ep)
+}
@@ -0,0 +1,163 @@
+package marvin
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MethodNotAllowedHandler will set the handler for requests to a registered path
+// with a method that has no endpoint. The 'Allow' header will already be set on the
+// response when the handler is called. By default, a plain 405 response is sent.
+func MethodNotAllowedHandler(h http.Handler) ServerOption {
+	return func(s *Server) {
+		s.methodNotAllowed = h
+	}
+}
+
+// methodTable knows every path and method registered with a Server so it can
+// answer OPTIONS, HEAD and 405s the same way regardless of the Router.
+type methodTable struct {
+	routes []*methodRoute
+	index  map[string]*methodRoute
+}
+
+type methodRoute struct {
+	re      *regexp.Regexp
+	methods map[string]bool
+}
+
+func newMethodTable() *methodTable {
+	return &methodTable{index: map[string]*methodRoute{}}
+}
+
+// add will record the method for the given Gorilla style path template.
+func (t *methodTable) add(method, path string) {
+	route, ok := t.index[path]
+	if !ok {
+		re, err := templateRegexp(path)
+		if err != nil {
+			// the router will complain about the path itself
+			return
+		}
+		route = &methodRoute{re: re, methods: map[string]bool{}}
+		t.index[path] = route
+		t.routes = append(t.routes, route)
+	}
+	route.methods[method] = true
+}
+
+// allowed returns the methods registered for any path template that matches the
+// given path. It returns nil if no template matches.
+func (t *methodTable) allowed(path string) map[string]bool {
+	var methods map[string]bool
+	for _, route := range t.routes {
+		if !route.re.MatchString(path) {
+			continue
+		}
+		if methods == nil {
+			methods = map[string]bool{}
+		}
+		for m := range route.methods {
+			methods[m] = true
+		}
+	}
+	return methods
+}
+
+// templateRegexp will compile a Gorilla style path template into a regular
+// expression that matches the paths it describes.
+func templateRegexp(tmpl string) (*regexp.Regexp, error) {
+	parts, err := parsePath(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	expr := "^"
+	for _, p := range parts {
+		switch {
+		case !p.isVar():
+			expr += regexp.QuoteMeta(p.literal)
+		case p.pattern != "":
+			expr += "(?:" + p.pattern + ")"
+		default:
+			expr += "[^/]+"
+		}
+	}
+	return regexp.Compile(expr + "$")
+}
+
+// allowHeader returns the value of the 'Allow' header for the given methods.
+// OPTIONS is always allowed and HEAD is allowed wherever GET is.
+func allowHeader(methods map[string]bool) string {
+	list := []string{http.MethodOptions}
+	for m := range methods {
+		if m != http.MethodOptions && m != http.MethodHead {
+			list = append(list, m)
+		}
+	}
+	if methods[http.MethodGet] || methods[http.MethodHead] {
+		list = append(list, http.MethodHead)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ", ")
+}
+
+// unmatchedRouter is implemented by the Routers that hand requests they have no
+// handler for to the Server before responding with a 404 or 405. The function
+// returns true if it answered the request.
+type unmatchedRouter interface {
+	setUnmatched(func(http.ResponseWriter, *http.Request) bool)
+}
+
+// handleMethods will answer OPTIONS requests, serve HEAD requests through GET
+// endpoints and respond to requests with unregistered methods with a 405. All
+// other requests are passed to the router. Routers that implement
+// unmatchedRouter only consult the method table for requests they cannot route,
+// so they are passed every request.
+func (s Server) handleMethods(next http.Handler) http.Handler {
+	if _, ok := s.mux.(unmatchedRouter); ok {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.answerMethods(w, r) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// answerMethods will answer requests for a registered path whose method has no
+// endpoint. It returns false for any other request.
+func (s Server) answerMethods(w http.ResponseWriter, r *http.Request) bool {
+	methods := s.methods.allowed(r.URL.Path)
+	// unknown paths are up to the router and "ANY" allows everything
+	if methods == nil || methods[r.Method] || methods["ANY"] {
+		return false
+	}
+	switch {
+	case r.Method == http.MethodOptions:
+		w.Header().Set("Allow", allowHeader(methods))
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodHead && methods[http.MethodGet]:
+		get := r.WithContext(r.Context())
+		get.Method = http.MethodGet
+		s.mux.ServeHTTP(headWriter{w}, get)
+	default:
+		w.Header().Set("Allow", allowHeader(methods))
+		if s.methodNotAllowed != nil {
+			s.methodNotAllowed.ServeHTTP(w, r)
+			return true
+		}
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+	return true
+}
+
+// headWriter will drop the body of responses to HEAD requests.
+type headWriter struct {
+	http.ResponseWriter
+}
+
+func (h headWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
@@ -0,0 +1,71 @@
+package marvin
+
+import (
+	"net/http"
+	"testing"
+)
+
+// customRouter hides everything but the Router methods of a marvin router.
+type customRouter struct {
+	Router
+}
+
+func TestHandleMethods(t *testing.T) {
+	endpoints := map[string]map[string]HTTPEndpoint{
+		"/cat/{id}": {
+			"GET":  testEndpoint("cat"),
+			"POST": testEndpoint("cat"),
+		},
+	}
+	teapot := MethodNotAllowedHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	tests := []struct {
+		name   string
+		opts   []ServerOption
+		method string
+		path   string
+
+		wantCode  int
+		wantAllow string
+		wantBody  bool
+	}{
+		{name: "get", method: "GET", path: "/cat/1", wantCode: http.StatusOK, wantBody: true},
+		{name: "options", method: "OPTIONS", path: "/cat/1", wantCode: http.StatusOK, wantAllow: "GET, HEAD, OPTIONS, POST"},
+		{name: "head", method: "HEAD", path: "/cat/1", wantCode: http.StatusOK},
+		{name: "not allowed", method: "DELETE", path: "/cat/1", wantCode: http.StatusMethodNotAllowed, wantAllow: "GET, HEAD, OPTIONS, POST", wantBody: true},
+		{name: "custom not allowed", opts: []ServerOption{teapot}, method: "DELETE", path: "/cat/1", wantCode: http.StatusTeapot, wantAllow: "GET, HEAD, OPTIONS, POST"},
+		{name: "unknown path", method: "OPTIONS", path: "/dog", wantCode: http.StatusNotFound, wantBody: true},
+	}
+	routers := []struct {
+		name string
+		opt  RouterOption
+	}{
+		{name: "gorilla", opt: RouterSelect("gorilla")},
+		{name: "stdlib", opt: RouterSelect("stdlib")},
+		{name: "httprouter", opt: RouterSelect("httprouter")},
+		// routers outside of marvin have every request checked up front
+		{name: "custom", opt: CustomRouter(customRouter{RouterSelect("gorilla")(nil)})},
+	}
+	for _, router := range routers {
+		for _, test := range tests {
+			t.Run(router.name+"/"+test.name, func(t *testing.T) {
+				svr := newTestServer(t, testService{
+					router:    []RouterOption{router.opt},
+					endpoints: endpoints,
+				}, test.opts...)
+				w := serve(svr, test.method, test.path, "")
+				if w.Code != test.wantCode {
+					t.Errorf("expected status %d, got %d", test.wantCode, w.Code)
+				}
+				if got := w.Header().Get("Allow"); got != test.wantAllow {
+					t.Errorf("expected Allow %q, got %q", test.wantAllow, got)
+				}
+				if got := w.Body.Len() > 0; got != test.wantBody {
+					t.Errorf("expected a body: %t, got %q", test.wantBody, w.Body)
+				}
+			})
+		}
+	}
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 	"net"
 	"net/http"
+	"os"
 	"strings"
 
 	"github.com/go-kit/kit/endpoint"
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/pkg/errors"
 	"google.golang.org/appengine"
+
+	"github.com/NYTimes/marvin/internal"
 )
 
 var defaultDenial = NewJSONStatusResponse(
@@ -22,24 +25,83 @@ var defaultDenial = NewJSONStatusResponse(
 // this handler will return with with the given denial response.
 //
 // If no denial is given, the server will respond with a 401 status code and a simple
-// JSON response. If you supply your own denial, we recommend you use the Proto/JSONStatusResponse
+// JSON response, or a problem document if the ProblemDocuments option is enabled.
+// If you supply your own denial, we recommend you use the Proto/JSONStatusResponse
 // structs to respond with a specific status code and the appropriate serialization.
 //
+// Requests are denied if the header was not populated in the context or the
+// request context is not an App Engine context, since the header can only be
+// trusted on App Engine.
+//
 // More info on the 'X-Appengine-Inbound-Appid' header here:
 // https://cloud.google.com/appengine/docs/standard/go/appidentity/#asserting_identity_to_other_app_engine_apps
 func Internal(ep endpoint.Endpoint, denial error) endpoint.Endpoint {
-	if denial == nil {
-		denial = defaultDenial
+	return internalFrom(nil, denial)(ep)
+}
+
+// InternalFrom is a middleware like Internal that will also accept requests from
+// any of the given App Engine app IDs, so several applications can call each other.
+// Denied requests get the same response as Internal without a denial. The app IDs
+// can be loaded from the environment with AppIDsFromEnv.
+func InternalFrom(appIDs ...string) endpoint.Middleware {
+	return internalFrom(appIDs, nil)
+}
+
+// internalFrom returns a middleware that only accepts requests from the current
+// app and the given app IDs.
+func internalFrom(appIDs []string, denial error) endpoint.Middleware {
+	allowed := make(map[string]bool, len(appIDs))
+	for _, id := range appIDs {
+		allowed[id] = true
 	}
-	return endpoint.Endpoint(func(ctx context.Context, r interface{}) (interface{}, error) {
-		// only accept requests from our app
-		if ctx.Value(ContextKeyInboundAppID).(string) != appengine.AppID(ctx) {
-			return nil, denial
-		}
-		return ep(ctx, r)
+	return endpoint.Middleware(func(ep endpoint.Endpoint) endpoint.Endpoint {
+		return endpoint.Endpoint(func(ctx context.Context, r interface{}) (interface{}, error) {
+			// only accept requests from our app or the allowed apps
+			if id := inboundAppID(ctx); id == "" || !allowed[id] && id != appengine.AppID(ctx) {
+				if denial != nil {
+					return nil, denial
+				}
+				if problemsEnabled(ctx) {
+					return nil, NewProblemResponse(http.StatusUnauthorized, "unauthorized")
+				}
+				return nil, defaultDenial
+			}
+			return ep(ctx, r)
+		})
 	})
 }
 
+// inboundAppID returns the 'X-Appengine-Inbound-Appid' of the request or an empty
+// string if it is missing or the context is not an App Engine context.
+func inboundAppID(ctx context.Context) string {
+	if !internal.IsAppEngine(ctx) {
+		return ""
+	}
+	id, _ := ctx.Value(ContextKeyInboundAppID).(string)
+	return id
+}
+
+// InternalAppIDsEnv is the environment variable AppIDsFromEnv reads.
+const InternalAppIDsEnv = "INTERNAL_APP_IDS"
+
+// ParseAppIDs will accept a comma delimited list of App Engine app IDs and return
+// them with any surrounding whitespace and empty entries removed.
+func ParseAppIDs(idStr string) []string {
+	var ids []string
+	for _, id := range strings.Split(idStr, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// AppIDsFromEnv returns the app IDs in the INTERNAL_APP_IDS environment variable
+// for use with InternalFrom.
+func AppIDsFromEnv() []string {
+	return ParseAppIDs(os.Getenv(InternalAppIDsEnv))
+}
+
 // CORSHandler is a middleware func for setting all headers that enable CORS.
 // If an originSuffix is provided, a strings.HasSuffix check will be performed
 // before adding any CORS header. If an empty string is provided, any Origin
@@ -83,7 +145,9 @@ func ParseIPNets(ipStr string) ([]*net.IPNet, error) {
 
 // AllowIPNets is a middleware to only allow access to requests that exist in one of the
 // given IPNets. If no IPNets are provided, all requests are allowed to pass through.
-// If the request is denied access, the given response will be returned.
+// If the request is denied access, the given response will be returned. If no denial
+// is given and the ProblemDocuments option is enabled, a 403 problem document will be
+// returned.
 func AllowIPNets(ipnets []*net.IPNet, denial interface{}) endpoint.Middleware {
 	return endpoint.Middleware(func(ep endpoint.Endpoint) endpoint.Endpoint {
 		if len(ipnets) == 0 {
@@ -91,12 +155,12 @@ func AllowIPNets(ipnets []*net.IPNet, denial interface{}) endpoint.Middleware {
 		}
 		return endpoint.Endpoint(func(ctx context.Context, r interface{}) (interface{}, error) {
 			// TODO: add check for forwarded-for header
-			addr := ctx.Value(httptransport.ContextKeyRequestRemoteAddr).(string)
+			addr, _ := ctx.Value(httptransport.ContextKeyRequestRemoteAddr).(string)
 			ip := net.ParseIP(addr)
 			if ip == nil {
 				ipStr, _, err := net.SplitHostPort(addr)
 				if err != nil {
-					return denial, nil
+					return ipDenial(ctx, denial), nil
 				}
 				ip = net.ParseIP(ipStr)
 			}
@@ -108,10 +172,18 @@ func AllowIPNets(ipnets []*net.IPNet, denial interface{}) endpoint.Middleware {
 				}
 			}
 			if !ok {
-				return denial, nil
+				return ipDenial(ctx, denial), nil
 			}
 			// all clear, pass on through
 			return ep(ctx, r)
 		})
 	})
 }
+
+// ipDenial returns the response for requests denied by AllowIPNets.
+func ipDenial(ctx context.Context, denial interface{}) interface{} {
+	if denial == nil && problemsEnabled(ctx) {
+		return NewProblemResponse(http.StatusForbidden, "forbidden")
+	}
+	return denial
+}
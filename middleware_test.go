@@ -0,0 +1,137 @@
+package marvin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/NYTimes/marvin/internal"
+)
+
+func TestCORSHandler(t *testing.T) {
+	h := CORSHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}), ".nytimes.com")
+
+	tests := []struct {
+		name   string
+		method string
+		origin string
+
+		wantCode   int
+		wantOrigin string
+	}{
+		{name: "allowed origin", method: "GET", origin: "https://www.nytimes.com", wantCode: http.StatusTeapot, wantOrigin: "https://www.nytimes.com"},
+		{name: "other origin", method: "GET", origin: "https://example.com", wantCode: http.StatusTeapot},
+		{name: "no origin", method: "GET", wantCode: http.StatusTeapot},
+		{name: "preflight", method: "OPTIONS", origin: "https://www.nytimes.com", wantCode: http.StatusOK, wantOrigin: "https://www.nytimes.com"},
+		{name: "options", method: "OPTIONS", wantCode: http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := serve(h, test.method, "/cat", "", "Origin", test.origin)
+			if w.Code != test.wantCode {
+				t.Errorf("expected status %d, got %d", test.wantCode, w.Code)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != test.wantOrigin {
+				t.Errorf("expected allowed origin %q, got %q", test.wantOrigin, got)
+			}
+		})
+	}
+}
+
+func TestInternalFrom(t *testing.T) {
+	ok := func(context.Context, interface{}) (interface{}, error) { return "ok", nil }
+	appEngine := internal.WithAppEngine(context.Background())
+	denial := errors.New("go away")
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		problems bool
+		ep       endpoint.Endpoint
+
+		wantErr error
+		wantRes interface{}
+	}{
+		{
+			name:    "allowed app",
+			ctx:     context.WithValue(appEngine, ContextKeyInboundAppID, "cats-app"),
+			ep:      InternalFrom("dogs-app", "cats-app")(ok),
+			wantRes: "ok",
+		},
+		{
+			name:    "no app ID",
+			ctx:     appEngine,
+			ep:      InternalFrom("cats-app")(ok),
+			wantErr: defaultDenial,
+		},
+		{
+			name:    "not App Engine",
+			ctx:     context.WithValue(context.Background(), ContextKeyInboundAppID, "cats-app"),
+			ep:      InternalFrom("cats-app")(ok),
+			wantErr: defaultDenial,
+		},
+		{
+			name:    "no context values",
+			ctx:     context.Background(),
+			ep:      Internal(ok, nil),
+			wantErr: defaultDenial,
+		},
+		{
+			name:    "custom denial",
+			ctx:     context.Background(),
+			ep:      Internal(ok, denial),
+			wantErr: denial,
+		},
+		{
+			name:     "problem document",
+			ctx:      context.Background(),
+			problems: true,
+			ep:       InternalFrom("cats-app")(ok),
+			wantErr:  NewProblemResponse(http.StatusUnauthorized, "unauthorized"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.WithValue(test.ctx, problemsKey, test.problems)
+			res, err := test.ep(ctx, nil)
+			if !reflect.DeepEqual(err, test.wantErr) {
+				t.Errorf("expected error %v, got %v", test.wantErr, err)
+			}
+			if res != test.wantRes {
+				t.Errorf("expected response %v, got %v", test.wantRes, res)
+			}
+		})
+	}
+}
+
+func TestParseAppIDs(t *testing.T) {
+	tests := []struct {
+		ids  string
+		want []string
+	}{
+		{ids: "", want: nil},
+		{ids: "cats-app", want: []string{"cats-app"}},
+		{ids: " cats-app , dogs-app", want: []string{"cats-app", "dogs-app"}},
+		{ids: "cats-app,,dogs-app,", want: []string{"cats-app", "dogs-app"}},
+	}
+	for _, test := range tests {
+		if got := ParseAppIDs(test.ids); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseAppIDs(%q) = %q, want %q", test.ids, got, test.want)
+		}
+	}
+}
+
+func TestAppIDsFromEnv(t *testing.T) {
+	defer os.Setenv(InternalAppIDsEnv, os.Getenv(InternalAppIDsEnv))
+	os.Setenv(InternalAppIDsEnv, "cats-app, dogs-app")
+	if got, want := AppIDsFromEnv(), []string{"cats-app", "dogs-app"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected app IDs %q, got %q", want, got)
+	}
+}
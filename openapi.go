@@ -0,0 +1,642 @@
+package marvin
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Describer can be set on an HTTPEndpoint to document it in the OpenAPI document
+// marvin generates for a service.
+type Describer interface {
+	Describe() Description
+}
+
+// Description documents an HTTPEndpoint. Request and response body schemas are
+// generated from example values of their types via reflection, using the same
+// `json` struct tags encoding/json relies on. Protobuf messages are described the
+// way the DefaultJSONCodec serializes them, with the JSON names from their
+// `protobuf` struct tags, 64-bit integers and enums as strings, the members of
+// their oneofs as fields and well-known types like Timestamp as their JSON values.
+type Description struct {
+	Summary     string
+	Description string
+	Tags        []string
+	Deprecated  bool
+
+	// Request is a value of the request body type, like &LinkRequest{}.
+	// Leave it nil for endpoints without a request body.
+	Request interface{}
+	// Responses maps status codes to a value of the response body type
+	// for that status. A nil value describes a response without a body.
+	Responses map[int]interface{}
+	// Params documents path, query and header parameters. Any variables
+	// in the endpoint's path that are not listed here will be added as
+	// required string parameters.
+	Params []Param
+}
+
+// Describe is to implement Describer.
+func (d Description) Describe() Description {
+	return d
+}
+
+// Param documents a path, query or header parameter of an HTTPEndpoint.
+type Param struct {
+	Name        string
+	Description string
+	// In is one of "path", "query" or "header".
+	In string
+	// Type is one of "string", "integer", "number" or "boolean".
+	// It defaults to "string".
+	Type     string
+	Required bool
+	Pattern  string
+}
+
+// OpenAPIInfo holds the top-level metadata for an OpenAPI document.
+type OpenAPIInfo struct {
+	Title       string
+	Description string
+	// Version is the version of the API, not the OpenAPI specification.
+	Version string
+	// Swagger will generate an OpenAPI 2.0 (Swagger) document instead of
+	// OpenAPI 3.0.
+	Swagger bool
+}
+
+// OpenAPIDocumenter can be implemented by a Service to have marvin serve an
+// OpenAPI document generated from its endpoint maps at /_marvin/openapi.json.
+type OpenAPIDocumenter interface {
+	OpenAPIInfo() OpenAPIInfo
+}
+
+const openAPIURI = "/_marvin/openapi.json"
+
+// OpenAPIHandler returns an http.Handler that serves the OpenAPI document for the
+// given service as JSON. The document is generated once, when the handler is
+// created.
+func OpenAPIHandler(svc interface{}, info OpenAPIInfo) http.Handler {
+	doc, err := json.Marshal(OpenAPIDocument(svc, info))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, "unable to generate OpenAPI document: "+err.Error(),
+				http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(doc)
+	})
+}
+
+// OpenAPIDocument will walk the endpoint maps of the given JSONEndpointer,
+// ProtoEndpointer or NegotiatedEndpointer and generate an OpenAPI document that
+// can be serialized with encoding/json. Endpoints with a Describer will have their
+// summaries, parameters and body schemas included.
+func OpenAPIDocument(svc interface{}, info OpenAPIInfo) map[string]interface{} {
+	g := &openAPIGen{
+		swagger: info.Swagger,
+		schemas: schemaGen{defs: map[string]interface{}{}, names: map[string]reflect.Type{}},
+	}
+	if g.swagger {
+		g.schemas.refPrefix = "#/definitions/"
+	} else {
+		g.schemas.refPrefix = "#/components/schemas/"
+	}
+
+	ops := map[string]map[string][]versionedOperation{}
+	for _, set := range endpointSets(svc) {
+		for path, epMethods := range set.endpoints {
+			parts, err := parsePath(path)
+			if err != nil {
+				continue
+			}
+			docPath := openAPIPath(parts)
+			if ops[docPath] == nil {
+				ops[docPath] = map[string][]versionedOperation{}
+			}
+			for method, ep := range epMethods {
+				method = strings.ToLower(method)
+				if hasOperationVersion(ops[docPath][method], set.version) {
+					// the Server refuses these, so keep the first
+					continue
+				}
+				ops[docPath][method] = append(ops[docPath][method], versionedOperation{
+					version: set.version,
+					op:      g.operation(parts, set.format, ep),
+				})
+			}
+		}
+	}
+	paths := make(map[string]map[string]interface{}, len(ops))
+	for docPath, methods := range ops {
+		paths[docPath] = make(map[string]interface{}, len(methods))
+		for method, vops := range methods {
+			paths[docPath][method] = g.mergeVersions(vops)
+		}
+	}
+
+	meta := map[string]interface{}{
+		"title":   info.Title,
+		"version": info.Version,
+	}
+	if info.Description != "" {
+		meta["description"] = info.Description
+	}
+	doc := map[string]interface{}{
+		"info":  meta,
+		"paths": paths,
+	}
+	if g.swagger {
+		doc["swagger"] = "2.0"
+		if len(g.schemas.defs) > 0 {
+			doc["definitions"] = g.schemas.defs
+		}
+		return doc
+	}
+	doc["openapi"] = "3.0.3"
+	if len(g.schemas.defs) > 0 {
+		doc["components"] = map[string]interface{}{"schemas": g.schemas.defs}
+	}
+	return doc
+}
+
+// openAPIPath will strip any patterns from the variables in a path template.
+func openAPIPath(parts []pathPart) string {
+	var path string
+	for _, p := range parts {
+		if p.isVar() {
+			path += "{" + p.name + "}"
+			continue
+		}
+		path += p.literal
+	}
+	return path
+}
+
+// formatMediaTypeList returns the media types an endpoint of the given format
+// will accept and produce.
+func formatMediaTypeList(f Format) []string {
+	switch f {
+	case FormatProto:
+		return []string{"application/x-protobuf"}
+	case FormatNegotiated:
+		return []string{"application/json", "application/x-protobuf"}
+	default:
+		return []string{"application/json"}
+	}
+}
+
+type openAPIGen struct {
+	swagger bool
+	schemas schemaGen
+}
+
+// versionedOperation is the operation for one version of a path and method.
+type versionedOperation struct {
+	version string
+	op      map[string]interface{}
+}
+
+func hasOperationVersion(ops []versionedOperation, version string) bool {
+	for _, o := range ops {
+		if o.version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeVersions will document every version of a path and method as a single
+// operation. The version served without an 'Accept-Version' header describes the
+// operation, the versions are listed in an 'Accept-Version' header parameter and
+// each version's operation is kept in the 'x-versions' extension.
+func (g *openAPIGen) mergeVersions(ops []versionedOperation) map[string]interface{} {
+	if len(ops) == 1 && ops[0].version == "" {
+		return ops[0].op
+	}
+	ops = append([]versionedOperation{}, ops...)
+	sort.Slice(ops, func(i, j int) bool {
+		return defaultVersionFirst(ops[i].version, ops[j].version)
+	})
+	var (
+		enum     []string
+		versions = map[string]interface{}{}
+	)
+	for _, o := range ops {
+		if o.version != "" {
+			enum = append(enum, o.version)
+			versions[o.version] = o.op
+		}
+	}
+
+	op := make(map[string]interface{}, len(ops[0].op)+1)
+	for k, v := range ops[0].op {
+		op[k] = v
+	}
+	param := g.param(Param{Name: "Accept-Version", In: "header", Description: "The version of the operation to call."})
+	if g.swagger {
+		param["enum"] = enum
+	} else {
+		param["schema"].(map[string]interface{})["enum"] = enum
+	}
+	params, _ := op["parameters"].([]interface{})
+	op["parameters"] = append(append([]interface{}{}, params...), param)
+	op["x-versions"] = versions
+	return op
+}
+
+func (g *openAPIGen) operation(parts []pathPart, f Format, ep HTTPEndpoint) map[string]interface{} {
+	var desc Description
+	if ep.Describer != nil {
+		desc = ep.Describer.Describe()
+	}
+	op := map[string]interface{}{}
+	if desc.Summary != "" {
+		op["summary"] = desc.Summary
+	}
+	if desc.Description != "" {
+		op["description"] = desc.Description
+	}
+	if len(desc.Tags) > 0 {
+		op["tags"] = desc.Tags
+	}
+	if desc.Deprecated {
+		op["deprecated"] = true
+	}
+	mediaTypes := formatMediaTypeList(f)
+	if g.swagger {
+		op["produces"] = mediaTypes
+	}
+
+	// add any path variables that were not described
+	params := desc.Params
+	for _, p := range parts {
+		if !p.isVar() || hasParam(params, p.name, "path") {
+			continue
+		}
+		params = append(params, Param{Name: p.name, In: "path", Required: true, Pattern: p.pattern})
+	}
+	var docParams []interface{}
+	for _, p := range params {
+		docParams = append(docParams, g.param(p))
+	}
+
+	if desc.Request != nil {
+		schema := g.schemas.schema(reflect.TypeOf(desc.Request))
+		if g.swagger {
+			op["consumes"] = mediaTypes
+			docParams = append(docParams, map[string]interface{}{
+				"name":     "body",
+				"in":       "body",
+				"required": true,
+				"schema":   schema,
+			})
+		} else {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  g.content(mediaTypes, schema),
+			}
+		}
+	}
+	if len(docParams) > 0 {
+		op["parameters"] = docParams
+	}
+
+	responses := map[string]interface{}{}
+	for code, res := range desc.Responses {
+		r := map[string]interface{}{"description": http.StatusText(code)}
+		if res != nil {
+			schema := g.schemas.schema(reflect.TypeOf(res))
+			if g.swagger {
+				r["schema"] = schema
+			} else {
+				r["content"] = g.content(mediaTypes, schema)
+			}
+		}
+		responses[strconv.Itoa(code)] = r
+	}
+	if len(responses) == 0 {
+		responses["200"] = map[string]interface{}{"description": http.StatusText(http.StatusOK)}
+	}
+	op["responses"] = responses
+	return op
+}
+
+func (g *openAPIGen) param(p Param) map[string]interface{} {
+	typ := p.Type
+	if typ == "" {
+		typ = "string"
+	}
+	schema := map[string]interface{}{"type": typ}
+	if p.Pattern != "" {
+		schema["pattern"] = p.Pattern
+	}
+	out := map[string]interface{}{
+		"name": p.Name,
+		"in":   p.In,
+	}
+	if p.Description != "" {
+		out["description"] = p.Description
+	}
+	if p.Required || p.In == "path" {
+		out["required"] = true
+	}
+	if g.swagger {
+		// Swagger 2.0 puts the schema fields on the parameter itself
+		for k, v := range schema {
+			out[k] = v
+		}
+		return out
+	}
+	out["schema"] = schema
+	return out
+}
+
+func (g *openAPIGen) content(mediaTypes []string, schema map[string]interface{}) map[string]interface{} {
+	content := map[string]interface{}{}
+	for _, mt := range mediaTypes {
+		content[mt] = map[string]interface{}{"schema": schema}
+	}
+	return content
+}
+
+func hasParam(params []Param, name, in string) bool {
+	for _, p := range params {
+		if p.Name == name && p.In == in {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaGen will generate JSON schemas from Go types. Named struct types are
+// added to defs and referenced.
+type schemaGen struct {
+	refPrefix string
+	defs      map[string]interface{}
+	names     map[string]reflect.Type
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *schemaGen) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t == timeType {
+			return map[string]interface{}{"type": "string", "format": "date-time"}
+		}
+		if wkt, ok := reflect.New(t).Interface().(wellKnownType); ok {
+			if schema, ok := wellKnownSchemas[wkt.XXX_WellKnownType()]; ok {
+				return schema()
+			}
+		}
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name := g.defName(t)
+		if _, ok := g.defs[name]; !ok {
+			// reserve the name first to handle recursive types
+			g.defs[name] = nil
+			g.defs[name] = g.object(t)
+		}
+		return map[string]interface{}{"$ref": g.refPrefix + name}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// defName returns a unique definition name for the given named type.
+func (g *schemaGen) defName(t reflect.Type) string {
+	name := t.Name()
+	if existing, ok := g.names[name]; ok && existing != t {
+		pkg := t.PkgPath()
+		name = pkg[strings.LastIndex(pkg, "/")+1:] + "." + name
+	}
+	g.names[name] = t
+	return name
+}
+
+func (g *schemaGen) object(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	g.fields(t, props)
+	return map[string]interface{}{"type": "object", "properties": props}
+}
+
+func (g *schemaGen) fields(t reflect.Type, props map[string]interface{}) {
+	isProto := isProtoMessage(t)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		// skip unexported fields and Protobuf internals
+		if f.PkgPath != "" && !f.Anonymous || strings.HasPrefix(f.Name, "XXX_") {
+			continue
+		}
+		if f.Tag.Get("protobuf_oneof") != "" {
+			continue
+		}
+		if tag := f.Tag.Get("protobuf"); isProto && tag != "" {
+			props[protoJSONName(tag)] = g.protoSchema(f)
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		// flatten embedded structs like encoding/json does
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			g.fields(ft, props)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type)
+	}
+	if isProto {
+		for _, f := range oneofFields(t) {
+			props[protoJSONName(f.Tag.Get("protobuf"))] = g.protoSchema(f)
+		}
+	}
+}
+
+// oneofFields returns the fields of the wrapper types of a Protobuf message's
+// oneofs, which jsonpb writes as fields of the message itself.
+func oneofFields(t reflect.Type) []reflect.StructField {
+	var wrappers []interface{}
+	for _, name := range []string{"XXX_OneofWrappers", "XXX_OneofFuncs"} {
+		m, ok := reflect.PtrTo(t).MethodByName(name)
+		if !ok {
+			continue
+		}
+		out := m.Func.Call([]reflect.Value{reflect.New(t)})
+		wrappers, _ = out[len(out)-1].Interface().([]interface{})
+		break
+	}
+	var fields []reflect.StructField
+	for _, w := range wrappers {
+		wt := reflect.TypeOf(w)
+		for wt.Kind() == reflect.Ptr {
+			wt = wt.Elem()
+		}
+		if wt.Kind() == reflect.Struct && wt.NumField() == 1 {
+			fields = append(fields, wt.Field(0))
+		}
+	}
+	return fields
+}
+
+// wellKnownType is implemented by the Protobuf well-known types, which jsonpb
+// serializes as strings or plain JSON values instead of objects.
+type wellKnownType interface {
+	XXX_WellKnownType() string
+}
+
+// wellKnownSchemas describe the well-known types the way jsonpb serializes them.
+var wellKnownSchemas = map[string]func() map[string]interface{}{
+	"Timestamp": func() map[string]interface{} {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	},
+	"Duration": func() map[string]interface{} {
+		return map[string]interface{}{"type": "string", "pattern": `^-?[0-9]+(\.[0-9]+)?s$`}
+	},
+	"DoubleValue": func() map[string]interface{} {
+		return map[string]interface{}{"type": "number", "format": "double"}
+	},
+	"FloatValue": func() map[string]interface{} {
+		return map[string]interface{}{"type": "number", "format": "float"}
+	},
+	"Int64Value": func() map[string]interface{} {
+		return map[string]interface{}{"type": "string", "format": "int64"}
+	},
+	"UInt64Value": func() map[string]interface{} {
+		return map[string]interface{}{"type": "string", "format": "uint64"}
+	},
+	"Int32Value": func() map[string]interface{} {
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	},
+	"UInt32Value": func() map[string]interface{} {
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	},
+	"BoolValue": func() map[string]interface{} {
+		return map[string]interface{}{"type": "boolean"}
+	},
+	"StringValue": func() map[string]interface{} {
+		return map[string]interface{}{"type": "string"}
+	},
+	"BytesValue": func() map[string]interface{} {
+		return map[string]interface{}{"type": "string", "format": "byte"}
+	},
+	"Struct": func() map[string]interface{} {
+		return map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{}}
+	},
+	"ListValue": func() map[string]interface{} {
+		return map[string]interface{}{"type": "array", "items": map[string]interface{}{}}
+	},
+	"Value": func() map[string]interface{} {
+		return map[string]interface{}{}
+	},
+	"Any": func() map[string]interface{} {
+		return map[string]interface{}{"type": "object"}
+	},
+}
+
+var (
+	protoMessageType  = reflect.TypeOf((*proto.Message)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// isProtoMessage returns true if values of the struct type are serialized by
+// the DefaultJSONCodec with jsonpb.
+func isProtoMessage(t reflect.Type) bool {
+	pt := reflect.PtrTo(t)
+	return pt.Implements(protoMessageType) && !pt.Implements(jsonMarshalerType)
+}
+
+// protoJSONName returns the name jsonpb uses for the field with the given
+// `protobuf` struct tag.
+func protoJSONName(tag string) string {
+	var name, jsonName string
+	for _, part := range strings.Split(tag, ",") {
+		switch {
+		case strings.HasPrefix(part, "name="):
+			name = part[len("name="):]
+		case strings.HasPrefix(part, "json="):
+			jsonName = part[len("json="):]
+		}
+	}
+	if DefaultJSONCodec.OrigName || jsonName == "" {
+		return name
+	}
+	return jsonName
+}
+
+// protoSchema returns the schema of a Protobuf message field as jsonpb
+// serializes it.
+func (g *schemaGen) protoSchema(f reflect.StructField) map[string]interface{} {
+	t := f.Type
+	switch {
+	case t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": g.protoScalar(t.Elem(), f.Tag.Get("protobuf")),
+		}
+	case t.Kind() == reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": g.protoScalar(t.Elem(), f.Tag.Get("protobuf_val")),
+		}
+	}
+	return g.protoScalar(t, f.Tag.Get("protobuf"))
+}
+
+// protoScalar returns the schema of a single Protobuf value. jsonpb writes enums
+// by name and 64-bit integers as strings.
+func (g *schemaGen) protoScalar(t reflect.Type, tag string) map[string]interface{} {
+	if strings.Contains(tag, ",enum=") {
+		return map[string]interface{}{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Int64:
+		return map[string]interface{}{"type": "string", "format": "int64"}
+	case reflect.Uint64:
+		return map[string]interface{}{"type": "string", "format": "uint64"}
+	}
+	return g.schema(t)
+}
@@ -0,0 +1,163 @@
+package marvin
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/golang/protobuf/ptypes/wrappers"
+)
+
+// testCat mirrors what protoc-gen-go generates for a message with snake_case,
+// 64-bit, well-known type and oneof fields.
+type testCat struct {
+	CatName string               `protobuf:"bytes,1,opt,name=cat_name,json=catName" json:"cat_name,omitempty"`
+	Lives   int64                `protobuf:"varint,2,opt,name=lives" json:"lives,omitempty"`
+	ToyIds  []int64              `protobuf:"varint,3,rep,packed,name=toy_ids,json=toyIds" json:"toy_ids,omitempty"`
+	Born    *timestamp.Timestamp `protobuf:"bytes,4,opt,name=born" json:"born,omitempty"`
+	Nap     *duration.Duration   `protobuf:"bytes,5,opt,name=nap" json:"nap,omitempty"`
+	Weight  *wrappers.Int64Value `protobuf:"bytes,6,opt,name=weight" json:"weight,omitempty"`
+	Happy   *wrappers.BoolValue  `protobuf:"bytes,7,opt,name=happy" json:"happy,omitempty"`
+	Owner   isTestCatOwner       `protobuf_oneof:"owner"`
+}
+
+type isTestCatOwner interface {
+	isTestCatOwner()
+}
+
+type testCatOwnerName struct {
+	OwnerName string `protobuf:"bytes,8,opt,name=owner_name,json=ownerName,oneof"`
+}
+
+func (*testCatOwnerName) isTestCatOwner() {}
+
+func (m *testCat) Reset()         { *m = testCat{} }
+func (m *testCat) String() string { return proto.CompactTextString(m) }
+func (*testCat) ProtoMessage()    {}
+
+func (*testCat) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), func(msg proto.Message) (n int), []interface{}) {
+	return nil, nil, nil, []interface{}{(*testCatOwnerName)(nil)}
+}
+
+func TestOpenAPIProtoSchema(t *testing.T) {
+	tests := []struct {
+		name     string
+		origName bool
+	}{
+		{name: "json names"},
+		{name: "original names", origName: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func(c JSONCodec) { DefaultJSONCodec = c }(DefaultJSONCodec)
+			DefaultJSONCodec = JSONCodec{EmitDefaults: true, OrigName: test.origName}
+
+			g := &schemaGen{refPrefix: "#/definitions/", defs: map[string]interface{}{}, names: map[string]reflect.Type{}}
+			g.schema(reflect.TypeOf(testCat{}))
+			props := g.defs["testCat"].(map[string]interface{})["properties"].(map[string]interface{})
+
+			b, err := DefaultJSONCodec.Marshal(&testCat{
+				Lives:  9,
+				ToyIds: []int64{1},
+				Born:   &timestamp.Timestamp{Seconds: 1},
+				Nap:    &duration.Duration{Seconds: 1},
+				Weight: &wrappers.Int64Value{Value: 9},
+				Happy:  &wrappers.BoolValue{Value: true},
+				Owner:  &testCatOwnerName{OwnerName: "kid"},
+			})
+			if err != nil {
+				t.Fatalf("unable to marshal: %s", err)
+			}
+			var wire map[string]interface{}
+			if err := json.Unmarshal(b, &wire); err != nil {
+				t.Fatalf("unable to parse JSON: %s", err)
+			}
+
+			if got, want := keys(props), keys(wire); !reflect.DeepEqual(got, want) {
+				t.Fatalf("expected properties %v to match the wire format %v", got, want)
+			}
+			for name, val := range wire {
+				prop := props[name].(map[string]interface{})
+				if items, ok := prop["items"].(map[string]interface{}); ok {
+					prop, val = items, val.([]interface{})[0]
+				}
+				if want := jsonSchemaType(val); prop["type"] != want {
+					t.Errorf("expected %s to be described as %s, got %v", name, want, prop["type"])
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAPIVersions(t *testing.T) {
+	described := func(summary string) HTTPEndpoint {
+		ep := testEndpoint(summary)
+		ep.Describer = Description{Summary: summary}
+		return ep
+	}
+	svc := groupService{
+		testService: testService{endpoints: map[string]map[string]HTTPEndpoint{
+			"/cat": {"GET": described("get cat")},
+		}},
+		groups: []Group{
+			{Version: "v1", JSONEndpoints: map[string]map[string]HTTPEndpoint{"/dog": {"GET": described("dog v1")}}},
+			{Version: "v2", JSONEndpoints: map[string]map[string]HTTPEndpoint{"/dog": {"GET": described("dog v2")}}},
+			{ProtoEndpoints: map[string]map[string]HTTPEndpoint{"/cat": {"PUT": described("put cat")}}},
+		},
+	}
+	for _, swagger := range []bool{false, true} {
+		doc := OpenAPIDocument(svc, OpenAPIInfo{Swagger: swagger})
+		paths := doc["paths"].(map[string]map[string]interface{})
+
+		cat := paths["/cat"]
+		if len(cat) != 2 || cat["get"] == nil || cat["put"] == nil {
+			t.Errorf("expected both sets to be documented for /cat, got %v", cat)
+		}
+
+		dog := paths["/dog"]["get"].(map[string]interface{})
+		if dog["summary"] != "dog v2" {
+			t.Errorf("expected the latest version to describe the operation, got %v", dog["summary"])
+		}
+		versions := dog["x-versions"].(map[string]interface{})
+		if len(versions) != 2 || versions["v1"].(map[string]interface{})["summary"] != "dog v1" {
+			t.Errorf("expected every version to be documented, got %v", versions)
+		}
+		params := dog["parameters"].([]interface{})
+		param := params[len(params)-1].(map[string]interface{})
+		enum := param["enum"]
+		if !swagger {
+			enum = param["schema"].(map[string]interface{})["enum"]
+		}
+		if param["name"] != "Accept-Version" || !reflect.DeepEqual(enum, []string{"v2", "v1"}) {
+			t.Errorf("expected an Accept-Version parameter with the versions, got %v", param)
+		}
+	}
+}
+
+// jsonSchemaType returns the JSON schema type of a decoded JSON value.
+func jsonSchemaType(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+func keys(m map[string]interface{}) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,92 @@
+package marvin
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// pathPart is either a literal piece of a path template or a variable
+// in the Gorilla style of `{name}` or `{name:pattern}`.
+type pathPart struct {
+	literal string
+	name    string
+	pattern string
+}
+
+func (p pathPart) isVar() bool {
+	return p.name != ""
+}
+
+// parsePath will split a Gorilla style path template like "/cat/{id:[0-9]+}.json"
+// into its literal and variable parts.
+func parsePath(tmpl string) ([]pathPart, error) {
+	var (
+		parts []pathPart
+		start int
+	)
+	for i := 0; i < len(tmpl); i++ {
+		if tmpl[i] != '{' {
+			continue
+		}
+		if i > start {
+			parts = append(parts, pathPart{literal: tmpl[start:i]})
+		}
+		// find the matching brace, patterns may contain braces of their own
+		depth, end := 0, -1
+		for j := i; j < len(tmpl); j++ {
+			switch tmpl[j] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+			}
+			if depth == 0 {
+				end = j
+				break
+			}
+		}
+		if end < 0 {
+			return nil, errors.Errorf("unbalanced braces in path %q", tmpl)
+		}
+		v := tmpl[i+1 : end]
+		name, pattern := v, ""
+		if idx := strings.Index(v, ":"); idx >= 0 {
+			name, pattern = v[:idx], v[idx+1:]
+		}
+		if name == "" {
+			return nil, errors.Errorf("missing variable name in path %q", tmpl)
+		}
+		parts = append(parts, pathPart{name: name, pattern: pattern})
+		i, start = end, end+1
+	}
+	if start < len(tmpl) {
+		parts = append(parts, pathPart{literal: tmpl[start:]})
+	}
+	return parts, nil
+}
+
+// expandPath will replace the variables in the given path template with their
+// values. It returns the unescaped and escaped forms of the resulting path.
+func expandPath(tmpl string, vars map[string]string) (string, string, error) {
+	parts, err := parsePath(tmpl)
+	if err != nil {
+		return "", "", err
+	}
+	var path, raw string
+	for _, p := range parts {
+		if !p.isVar() {
+			path += p.literal
+			raw += p.literal
+			continue
+		}
+		val, ok := vars[p.name]
+		if !ok {
+			return "", "", errors.Errorf("missing path variable %q for %q", p.name, tmpl)
+		}
+		path += val
+		raw += url.PathEscape(val)
+	}
+	return path, raw, nil
+}
@@ -0,0 +1,147 @@
+package marvin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/golang/protobuf/proto"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem documents.
+const ProblemContentType = "application/problem+json"
+
+// ProblemDocuments is a server option that will make marvin describe errors with
+// RFC 7807 problem documents. With it, the default denials of Internal and
+// AllowIPNets and any errors handled by EncodeError will respond with a
+// ProblemResponse instead of their usual bodies. Callers that negotiate Protobuf
+// responses will receive the equivalent Problem message.
+//
+// To enable it for a whole service, include it in the service's Options().
+func ProblemDocuments() httptransport.ServerOption {
+	return httptransport.ServerBefore(
+		func(ctx context.Context, _ *http.Request) context.Context {
+			return context.WithValue(ctx, problemsKey, true)
+		})
+}
+
+// problemsEnabled returns true if the ProblemDocuments option is enabled for
+// the request.
+func problemsEnabled(ctx context.Context) bool {
+	enabled, _ := ctx.Value(problemsKey).(bool)
+	return enabled
+}
+
+// NewProblemResponse returns a ProblemResponse of the default 'about:blank' type
+// with the given status code and detail.
+func NewProblemResponse(status int, detail string) *ProblemResponse {
+	return &ProblemResponse{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// ProblemResponse is an RFC 7807 problem document. It implements:
+// `httptransport.StatusCoder` to respond with its Status.
+// `httptransport.Headerer` to respond with the 'application/problem+json' Content-Type.
+// `json.Marshaler` to serialize as a problem document with its Extensions as
+// additional members.
+// `proto.Marshaler` and proto.Message so it can be sent to Protobuf callers as a
+// Problem message.
+// `error` so it can be used to respond as an error within the go-kit stack.
+type ProblemResponse struct {
+	// Type is a URI reference that identifies the problem type.
+	Type string
+	// Title is a short, human-readable summary of the problem type.
+	Title string
+	// Status is the HTTP status code of the response.
+	Status int
+	// Detail is a human-readable explanation specific to this occurrence.
+	Detail string
+	// Instance is a URI reference that identifies this occurrence.
+	Instance string
+	// Extensions are added to the document as additional members.
+	Extensions map[string]string
+}
+
+// Error is to implement error
+func (p *ProblemResponse) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+	return p.Title + ": " + p.Detail
+}
+
+// StatusCode is to implement httptransport.StatusCoder
+func (p *ProblemResponse) StatusCode() int {
+	if p.Status == 0 {
+		return http.StatusInternalServerError
+	}
+	return p.Status
+}
+
+// Headers is to implement httptransport.Headerer
+func (p *ProblemResponse) Headers() http.Header {
+	return http.Header{"Content-Type": []string{ProblemContentType}}
+}
+
+// MarshalJSON is to implement json.Marshaler
+func (p *ProblemResponse) MarshalJSON() ([]byte, error) {
+	doc := map[string]interface{}{}
+	for k, v := range p.Extensions {
+		doc[k] = v
+	}
+	if p.Type != "" {
+		doc["type"] = p.Type
+	}
+	if p.Title != "" {
+		doc["title"] = p.Title
+	}
+	doc["status"] = p.StatusCode()
+	if p.Detail != "" {
+		doc["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		doc["instance"] = p.Instance
+	}
+	return json.Marshal(doc)
+}
+
+// Proto returns the Problem message equivalent of the document.
+func (p *ProblemResponse) Proto() *Problem {
+	return &Problem{
+		Type:       p.Type,
+		Title:      p.Title,
+		Status:     int32(p.StatusCode()),
+		Detail:     p.Detail,
+		Instance:   p.Instance,
+		Extensions: p.Extensions,
+	}
+}
+
+// to implement proto.Marshaler
+func (p *ProblemResponse) Marshal() ([]byte, error) {
+	return proto.Marshal(p.Proto())
+}
+
+// to implement proto.Message
+func (p *ProblemResponse) Reset()         { *p = ProblemResponse{} }
+func (p *ProblemResponse) String() string { return p.Proto().String() }
+func (p *ProblemResponse) ProtoMessage()  {}
+
+var _ proto.Marshaler = &ProblemResponse{}
+
+// toProblem will convert an Error into a problem document. The Code and
+// Details of the Error are added as extensions.
+func (e *Error) toProblem() *ProblemResponse {
+	p := NewProblemResponse(e.StatusCode(), e.Message)
+	p.Extensions = map[string]string{}
+	for k, v := range e.Details {
+		p.Extensions[k] = v
+	}
+	p.Extensions["code"] = e.Code.String()
+	return p
+}
@@ -0,0 +1,276 @@
+package marvin
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RouteError describes a route that could not be registered.
+type RouteError struct {
+	Method string
+	Path   string
+	Reason string
+}
+
+// Error is to implement error
+func (e RouteError) Error() string {
+	if e.Method == "" && e.Path == "" {
+		return e.Reason
+	}
+	return e.Method + " " + e.Path + ": " + e.Reason
+}
+
+// RegistrationError is returned by NewServerE and lists every route of the
+// service that could not be registered.
+type RegistrationError struct {
+	Routes []RouteError
+}
+
+// Error is to implement error
+func (e *RegistrationError) Error() string {
+	msgs := make([]string, len(e.Routes))
+	for i, r := range e.Routes {
+		msgs[i] = r.Error()
+	}
+	return strconv.Itoa(len(e.Routes)) + " invalid route(s): " + strings.Join(msgs, "; ")
+}
+
+func (e *RegistrationError) add(method, path, reason string) {
+	e.Routes = append(e.Routes, RouteError{Method: method, Path: path, Reason: reason})
+}
+
+// sort will order the routes by path and method.
+func (e *RegistrationError) sort() {
+	sort.SliceStable(e.Routes, func(i, j int) bool {
+		if e.Routes[i].Path != e.Routes[j].Path {
+			return e.Routes[i].Path < e.Routes[j].Path
+		}
+		return e.Routes[i].Method < e.Routes[j].Method
+	})
+}
+
+// checkRoute returns the reason the endpoint for a path and method is invalid or
+// an empty string if it is valid.
+func checkRoute(method, path string, ep HTTPEndpoint) string {
+	if !validMethod(method) {
+		return "invalid HTTP method"
+	}
+	if !strings.HasPrefix(path, "/") {
+		return "paths must start with '/'"
+	}
+	if _, err := parsePath(path); err != nil {
+		return err.Error()
+	}
+	if _, err := templateRegexp(path); err != nil {
+		return "invalid variable pattern: " + err.Error()
+	}
+	if ep.Endpoint == nil {
+		return "missing Endpoint"
+	}
+	return ""
+}
+
+// validMethod will only accept upper case HTTP method tokens, like "GET", or
+// "ANY" to accept all methods.
+func validMethod(method string) bool {
+	if method == "" {
+		return false
+	}
+	for _, c := range method {
+		if (c < 'A' || c > 'Z') && c != '-' && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// Route specificity ranks for a path segment. Lower ranks are more specific.
+const (
+	rankLiteral = iota
+	rankPattern
+	// a variable with some literal text, like "{id}.json"
+	rankPartial
+	rankVar
+	rankCatchAll
+)
+
+// routeSegment is a single '/' separated segment of a path template.
+type routeSegment struct {
+	tmpl      string
+	rank      int
+	patterned bool
+	re        *regexp.Regexp
+}
+
+// routeSegments will split a path template into its segments. Variables with
+// patterns that can match a '/' swallow the rest of the path.
+func routeSegments(path string) []routeSegment {
+	parts, _ := parsePath(path)
+	var (
+		segs []routeSegment
+		cur  []pathPart
+	)
+	flush := func() {
+		seg := routeSegment{rank: rankLiteral}
+		expr := "^"
+		for _, p := range cur {
+			switch {
+			case !p.isVar():
+				seg.tmpl += p.literal
+				expr += regexp.QuoteMeta(p.literal)
+			case p.pattern != "":
+				seg.tmpl += "{" + p.name + ":" + p.pattern + "}"
+				expr += "(?:" + p.pattern + ")"
+				seg.patterned = true
+				if seg.rank < rankPattern {
+					seg.rank = rankPattern
+				}
+			default:
+				seg.tmpl += "{}"
+				expr += "[^/]+"
+				seg.rank = rankVar
+			}
+		}
+		if seg.rank == rankVar && len(cur) > 1 {
+			seg.rank = rankPartial
+		}
+		seg.re, _ = regexp.Compile(expr + "$")
+		segs = append(segs, seg)
+		cur = nil
+	}
+	for _, p := range parts {
+		if p.isVar() {
+			if re, err := regexp.Compile(p.pattern); err == nil && p.pattern != "" && re.MatchString("a/b") {
+				flush()
+				segs[len(segs)-1].rank = rankCatchAll
+				return segs
+			}
+			cur = append(cur, p)
+			continue
+		}
+		lits := strings.Split(p.literal, "/")
+		for i, lit := range lits {
+			if i > 0 {
+				flush()
+			}
+			if lit != "" {
+				cur = append(cur, pathPart{literal: lit})
+			}
+		}
+	}
+	flush()
+	return segs
+}
+
+// sample returns a value the segment will match, if one can be made.
+func (s routeSegment) sample() (string, bool) {
+	if s.rank == rankLiteral {
+		return s.tmpl, true
+	}
+	if !s.patterned {
+		v := strings.Replace(s.tmpl, "{}", "x", -1)
+		return v, s.re.MatchString(v)
+	}
+	return "", false
+}
+
+// overlaps returns true if both segments may match the same text.
+func (s routeSegment) overlaps(o routeSegment) bool {
+	if s.rank == rankCatchAll || o.rank == rankCatchAll {
+		return true
+	}
+	if s.tmpl == o.tmpl {
+		return true
+	}
+	if v, ok := s.sample(); ok && o.re.MatchString(v) {
+		return true
+	}
+	if v, ok := o.sample(); ok && s.re.MatchString(v) {
+		return true
+	}
+	return false
+}
+
+// ranks returns the specificity of each of the segments.
+func ranks(segs []routeSegment) []int {
+	r := make([]int, len(segs))
+	for i, s := range segs {
+		r[i] = s.rank
+	}
+	return r
+}
+
+// ambiguousRoutes returns true if some path could match both templates and
+// neither is more specific than the other in every segment.
+func ambiguousRoutes(a, b string) bool {
+	as, bs := routeSegments(a), routeSegments(b)
+	n := len(as)
+	if len(bs) < n {
+		n = len(bs)
+	}
+	for i := 0; i < n; i++ {
+		if !as[i].overlaps(bs[i]) {
+			return false
+		}
+		if as[i].rank == rankCatchAll || bs[i].rank == rankCatchAll {
+			break
+		}
+	}
+	// paths of different lengths can only match the same path if the
+	// shorter one ends with a catch-all
+	catchAll := func(segs []routeSegment) bool {
+		return len(segs) > 0 && segs[len(segs)-1].rank == rankCatchAll
+	}
+	switch {
+	case len(as) < len(bs) && !catchAll(as), len(bs) < len(as) && !catchAll(bs):
+		return false
+	}
+	ar, br := ranks(as), ranks(bs)
+	var aWins, bWins bool
+	for i := 0; i < len(ar) || i < len(br); i++ {
+		x, y := rankCatchAll, rankCatchAll
+		if i < len(ar) {
+			x = ar[i]
+		}
+		if i < len(br) {
+			y = br[i]
+		}
+		if x < y {
+			aWins = true
+		}
+		if y < x {
+			bWins = true
+		}
+	}
+	return aWins == bWins
+}
+
+// sortRoutes will sort path templates so more specific paths come first, then
+// alphabetically. Routers that pick the first matching route, like Gorilla's,
+// will prefer "/cat/new" over "/cat/{id}" regardless of map order.
+func sortRoutes(paths []string) {
+	rs := make(map[string][]int, len(paths))
+	for _, p := range paths {
+		rs[p] = ranks(routeSegments(p))
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if c := compareRanks(rs[paths[i]], rs[paths[j]]); c != 0 {
+			return c < 0
+		}
+		return paths[i] < paths[j]
+	})
+}
+
+// compareRanks returns a negative number if the route with the first ranks is
+// more specific than the second, a positive one if it is less specific and zero
+// if neither is.
+func compareRanks(a, b []int) int {
+	for k := 0; k < len(a) && k < len(b); k++ {
+		if a[k] != b[k] {
+			return a[k] - b[k]
+		}
+	}
+	return len(b) - len(a)
+}
@@ -0,0 +1,118 @@
+package marvin
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestNewServerE(t *testing.T) {
+	ok := testEndpoint("ok")
+	tests := []struct {
+		name      string
+		router    string
+		endpoints map[string]map[string]HTTPEndpoint
+
+		wantRoutes []RouteError
+	}{
+		{
+			name:   "valid",
+			router: "gorilla",
+			endpoints: map[string]map[string]HTTPEndpoint{
+				"/cat/{id}": {"GET": ok},
+				"/cat/new":  {"GET": ok},
+			},
+		},
+		{
+			name:   "invalid",
+			router: "gorilla",
+			endpoints: map[string]map[string]HTTPEndpoint{
+				"/cat":     {"get": ok},
+				"cat":      {"GET": ok},
+				"/cat/new": {"GET": {}},
+			},
+			wantRoutes: []RouteError{
+				{Method: "get", Path: "/cat"},
+				{Method: "GET", Path: "/cat/new"},
+				{Method: "GET", Path: "cat"},
+			},
+		},
+		{
+			name:   "ambiguous",
+			router: "gorilla",
+			endpoints: map[string]map[string]HTTPEndpoint{
+				"/cat/{id}/toys":   {"GET": ok},
+				"/{kind}/new/toys": {"GET": ok},
+			},
+			wantRoutes: []RouteError{{Method: "GET", Path: "/cat/{id}/toys"}},
+		},
+		{
+			name:   "httprouter conflict",
+			router: "httprouter",
+			endpoints: map[string]map[string]HTTPEndpoint{
+				"/cat/{id}": {"GET": ok},
+				"/cat/new":  {"GET": ok},
+			},
+			wantRoutes: []RouteError{{Method: "GET", Path: "/cat/{id}"}},
+		},
+		{
+			name:   "httprouter partial segment",
+			router: "httprouter",
+			endpoints: map[string]map[string]HTTPEndpoint{
+				"/cat/{id}.json": {"GET": ok},
+			},
+			wantRoutes: []RouteError{{Method: "GET", Path: "/cat/{id}.json"}},
+		},
+		{
+			name:   "stdlib",
+			router: "stdlib",
+			endpoints: map[string]map[string]HTTPEndpoint{
+				"/cat/{id}":      {"GET": ok},
+				"/cat/{name}":    {"PUT": ok},
+				"/cat/new":       {"GET": ok},
+				"/cat/{id}.json": {"GET": ok},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewServerE(testService{
+				router:    []RouterOption{RouterSelect(test.router)},
+				endpoints: test.endpoints,
+			}, BaseContext(StandardContext))
+			if len(test.wantRoutes) == 0 {
+				if err != nil {
+					t.Fatalf("expected no error, got %s", err)
+				}
+				return
+			}
+			rerr, isRegErr := err.(*RegistrationError)
+			if !isRegErr {
+				t.Fatalf("expected a *RegistrationError, got %v", err)
+			}
+			var got []RouteError
+			for _, r := range rerr.Routes {
+				if r.Reason == "" {
+					t.Errorf("expected a reason for %s %s", r.Method, r.Path)
+				}
+				got = append(got, RouteError{Method: r.Method, Path: r.Path})
+			}
+			if !reflect.DeepEqual(got, test.wantRoutes) {
+				t.Errorf("expected route errors %v, got %v", test.wantRoutes, got)
+			}
+		})
+	}
+}
+
+func TestNewServerEDeterministic(t *testing.T) {
+	svc := testService{endpoints: map[string]map[string]HTTPEndpoint{
+		"/cat/{id}": {"GET": testEndpoint("id")},
+		"/cat/new":  {"GET": testEndpoint("new")},
+	}}
+	for i := 0; i < 10; i++ {
+		svr := newTestServer(t, svc)
+		if w := serve(svr, http.MethodGet, "/cat/new", ""); w.Body.String() != `"new"` {
+			t.Fatalf("expected the literal route to win, got %s", w.Body)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package marvin
 
 import (
-	"encoding/json"
 	"net/http"
 
 	"github.com/golang/protobuf/proto"
@@ -39,9 +38,9 @@ func (c *ProtoStatusResponse) Reset()         { c.res.Reset() }
 func (c *ProtoStatusResponse) String() string { return c.res.String() }
 func (c *ProtoStatusResponse) ProtoMessage()  { c.res.ProtoMessage() }
 
-// to implement json.Marshaler
+// to implement json.Marshaler with the DefaultJSONCodec
 func (c *ProtoStatusResponse) MarshalJSON() ([]byte, error) {
-	return json.Marshal(c.res)
+	return DefaultJSONCodec.Marshal(c.res)
 }
 
 var _ proto.Marshaler = &ProtoStatusResponse{}
@@ -72,9 +71,9 @@ func (c *JSONStatusResponse) StatusCode() int {
 	return c.code
 }
 
-// MarshalJSON is to implement json.Marshaler
+// MarshalJSON is to implement json.Marshaler with the DefaultJSONCodec
 func (c *JSONStatusResponse) MarshalJSON() ([]byte, error) {
-	return json.Marshal(c.res)
+	return DefaultJSONCodec.Marshal(c.res)
 }
 
 // Error is to implement error
@@ -3,6 +3,10 @@ package marvin
 import (
 	"context"
 	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 )
@@ -24,6 +28,7 @@ type RouterOption func(Router) Router
 // The following router names are accepted:
 // * `gorilla` which uses github.com/gorilla/mux (on by default)
 // * `stdlib` to utilize the standard library's http.ServeMux
+// * `httprouter` to utilize github.com/julienschmidt/httprouter
 //
 // If the supplied name does not match any known router, `gorilla` will be used.
 // If a user wishes to supply their own router implementation, the `CustomRouter` option
@@ -32,11 +37,13 @@ func RouterSelect(name string) RouterOption {
 	return func(_ Router) Router {
 		switch name {
 		case "gorilla":
-			return &GorillaRouter{mux.NewRouter()}
+			return newGorillaRouter()
 		case "stdlib":
-			return &StdlibRouter{http.NewServeMux()}
+			return NewStdlibRouter()
+		case "httprouter":
+			return NewHTTPRouter()
 		default:
-			return &GorillaRouter{mux.NewRouter()}
+			return newGorillaRouter()
 		}
 	}
 }
@@ -57,30 +64,149 @@ func RouterNotFound(h http.Handler) RouterOption {
 }
 
 // StdlibRouter is a Router implementation for the Stdlib's `http.ServeMux`.
+// Each path is registered with the ServeMux once and dispatches to a table of
+// handlers by HTTP method, so requests with a method that has no handler will
+// get a 405 response with an 'Allow' header.
+//
+// On Go 1.22+, unless the ServeMux runs in its legacy mode, Gorilla style path
+// variables like `{id}` are registered as ServeMux wildcards and populated via
+// SetRouteVars. Variables with patterns,
+// like `{id:[0-9]+}`, are checked against their pattern after the route matches
+// and a trailing `{path:.*}` becomes the `{path...}` wildcard. Paths of the same
+// shape, like `/cat/{id}` and `/cat/{name}`, share a method table. Variables the
+// ServeMux cannot express, like the one in `/cat/{id}.json`, are matched with a
+// regular expression instead and win over a ServeMux match when they are more
+// specific.
 type StdlibRouter struct {
-	mux *http.ServeMux
+	mux       *http.ServeMux
+	routes    map[string]*stdlibRoute
+	matchers  []*stdlibRoute
+	notFound  http.Handler
+	unmatched func(http.ResponseWriter, *http.Request) bool
 }
 
-// Handle will call the Stdlib's HandleFunc() methods with a check for the incoming
-// HTTP method. To allow for multiple methods on a single route, use 'ANY'.
-func (g *StdlibRouter) Handle(method, path string, h http.Handler) {
-	g.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == method || method == "ANY" {
-			h.ServeHTTP(w, r)
-			return
+// stdlibVar is a path variable of a StdlibRouter route. The wildcard is the name
+// of the ServeMux wildcard or regular expression group that holds its value.
+type stdlibVar struct {
+	name     string
+	wildcard string
+	pattern  *regexp.Regexp
+}
+
+// stdlibRoute holds the handlers of a single path. Routes the ServeMux cannot
+// express match with a regular expression.
+type stdlibRoute struct {
+	ranks    []int
+	re       *regexp.Regexp
+	handlers map[string][]stdlibHandler
+}
+
+// stdlibHandler is a handler with the variables of the path it was added with.
+type stdlibHandler struct {
+	vars []stdlibVar
+	h    http.Handler
+}
+
+// allow returns the value of the 'Allow' header for the route.
+func (s *stdlibRoute) allow() string {
+	var methods []string
+	for m := range s.handlers {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
+// vars will pull the handler's variables out of the request. It returns false if
+// the request does not match them.
+func (s *stdlibRoute) vars(r *http.Request, vars []stdlibVar) (map[string]string, bool) {
+	if s.re == nil {
+		return stdlibVars(r, vars)
+	}
+	m := s.re.FindStringSubmatch(r.URL.Path)
+	if m == nil {
+		return nil, false
+	}
+	out := make(map[string]string, len(vars))
+	for _, v := range vars {
+		out[v.name] = m[s.re.SubexpIndex(v.wildcard)]
+	}
+	return out, true
+}
+
+// stdlibRegexp will translate a Gorilla style path template into a regular
+// expression with a group for each variable. Like with stdlibPattern, the groups
+// are named by position so paths of the same shape share an expression.
+func stdlibRegexp(path string) (string, []stdlibVar) {
+	parts, _ := parsePath(path)
+	var (
+		expr = "^"
+		vars []stdlibVar
+	)
+	for _, p := range parts {
+		if !p.isVar() {
+			expr += regexp.QuoteMeta(p.literal)
+			continue
+		}
+		v := stdlibVar{name: p.name, wildcard: "v" + strconv.Itoa(len(vars))}
+		pattern := p.pattern
+		if pattern == "" {
+			pattern = "[^/]+"
 		}
-		http.NotFound(w, r)
+		expr += "(?P<" + v.wildcard + ">" + pattern + ")"
+		vars = append(vars, v)
+	}
+	return expr + "$", vars
+}
+
+// NewStdlibRouter returns a StdlibRouter with a new http.ServeMux.
+func NewStdlibRouter() *StdlibRouter {
+	g := &StdlibRouter{mux: http.NewServeMux(), routes: map[string]*stdlibRoute{}}
+	// requests no other path matches may still match a regular expression route
+	g.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		g.dispatch(g.routes["/"], w, r)
 	})
+	return g
 }
 
-// HandleFunc will call the Stdlib's HandleFunc() methods with a check for the incoming
-// HTTP method. To allow for multiple methods on a single route, use 'ANY'.
+// Handle will add the handler to the method table of the path, registering the
+// path with the ServeMux the first time it is seen. To allow for multiple methods
+// on a single route, use 'ANY'.
+func (g *StdlibRouter) Handle(method, path string, h http.Handler) {
+	key, vars, ok := stdlibPattern(path)
+	if !ok {
+		key, vars = stdlibRegexp(path)
+	}
+	route, exists := g.routes[key]
+	if !exists {
+		route = &stdlibRoute{ranks: ranks(routeSegments(path)), handlers: map[string][]stdlibHandler{}}
+		switch {
+		case !ok:
+			route.re = regexp.MustCompile(key)
+			g.matchers = append(g.matchers, route)
+			sort.SliceStable(g.matchers, func(i, j int) bool {
+				return compareRanks(g.matchers[i].ranks, g.matchers[j].ranks) < 0
+			})
+		case key != "/":
+			// the root path is always registered
+			g.mux.HandleFunc(key, func(w http.ResponseWriter, r *http.Request) {
+				g.dispatch(route, w, r)
+			})
+		}
+		g.routes[key] = route
+	}
+	route.handlers[method] = append(route.handlers[method], stdlibHandler{vars: vars, h: h})
+}
+
+// HandleFunc will add the handler to the method table of the path. To allow for
+// multiple methods on a single route, use 'ANY'.
 func (g *StdlibRouter) HandleFunc(method, path string, h func(http.ResponseWriter, *http.Request)) {
 	g.Handle(method, path, http.HandlerFunc(h))
 }
 
-// SetNotFoundHandler will do nothing as we cannot override the stdlib not found.
+// SetNotFoundHandler will set the handler for requests that do not match any path.
 func (g *StdlibRouter) SetNotFoundHandler(h http.Handler) {
+	g.notFound = h
 }
 
 // ServeHTTP will call Stdlib's ServeMux.ServerHTTP directly.
@@ -88,9 +214,76 @@ func (g *StdlibRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	g.mux.ServeHTTP(w, r)
 }
 
+// dispatch will serve the request with the route the ServeMux matched, unless a
+// more specific regular expression route matches it as well. A nil route means
+// the ServeMux matched no path.
+func (g *StdlibRouter) dispatch(route *stdlibRoute, w http.ResponseWriter, r *http.Request) {
+	for _, m := range g.matchers {
+		if !m.re.MatchString(r.URL.Path) {
+			continue
+		}
+		if route == nil || compareRanks(m.ranks, route.ranks) < 0 {
+			route = m
+		}
+		break
+	}
+	if route == nil {
+		g.handleNotFound(w, r)
+		return
+	}
+	g.serve(route, w, r)
+}
+
+// serve will pick the route's handler for the request method whose variables
+// match the request.
+func (g *StdlibRouter) serve(route *stdlibRoute, w http.ResponseWriter, r *http.Request) {
+	hs := route.handlers[r.Method]
+	if anyHs := route.handlers["ANY"]; len(anyHs) > 0 {
+		hs = append(hs[:len(hs):len(hs)], anyHs...)
+	}
+	if len(hs) == 0 {
+		if g.unmatched != nil && g.unmatched(w, r) {
+			return
+		}
+		w.Header().Set("Allow", route.allow())
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	for _, h := range hs {
+		if vars, ok := route.vars(r, h.vars); ok {
+			h.h.ServeHTTP(w, SetRouteVars(r, vars))
+			return
+		}
+	}
+	g.handleNotFound(w, r)
+}
+
+func (g *StdlibRouter) handleNotFound(w http.ResponseWriter, r *http.Request) {
+	if g.unmatched != nil && g.unmatched(w, r) {
+		return
+	}
+	if g.notFound != nil {
+		g.notFound.ServeHTTP(w, r)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func (g *StdlibRouter) setUnmatched(f func(http.ResponseWriter, *http.Request) bool) {
+	g.unmatched = f
+}
+
 // GorillaRouter is a Router implementation for the Gorilla web toolkit's `mux.Router`.
 type GorillaRouter struct {
-	mux *mux.Router
+	mux       *mux.Router
+	notFound  http.Handler
+	unmatched func(http.ResponseWriter, *http.Request) bool
+}
+
+func newGorillaRouter() *GorillaRouter {
+	g := &GorillaRouter{mux: mux.NewRouter()}
+	g.mux.NotFoundHandler = http.HandlerFunc(g.handleNotFound)
+	return g
 }
 
 // Handle will call the Gorilla web toolkit's Handle().Method() methods.
@@ -109,9 +302,10 @@ func (g *GorillaRouter) HandleFunc(method, path string, h func(http.ResponseWrit
 	g.Handle(method, path, http.HandlerFunc(h))
 }
 
-// SetNotFoundHandler will set the Gorilla mux.Router.NotFoundHandler.
+// SetNotFoundHandler will set the handler Gorilla's mux.Router.NotFoundHandler
+// calls.
 func (g *GorillaRouter) SetNotFoundHandler(h http.Handler) {
-	g.mux.NotFoundHandler = h
+	g.notFound = h
 }
 
 // ServeHTTP will call Gorilla mux.Router.ServerHTTP directly.
@@ -119,6 +313,21 @@ func (g *GorillaRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	g.mux.ServeHTTP(w, r)
 }
 
+func (g *GorillaRouter) handleNotFound(w http.ResponseWriter, r *http.Request) {
+	if g.unmatched != nil && g.unmatched(w, r) {
+		return
+	}
+	if g.notFound != nil {
+		g.notFound.ServeHTTP(w, r)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func (g *GorillaRouter) setUnmatched(f func(http.ResponseWriter, *http.Request) bool) {
+	g.unmatched = f
+}
+
 // Vars is a helper function for accessing route
 // parameters from any server.Router implementation. This is the equivalent
 // of using `mux.Vars(r)` with the Gorilla mux.Router.
@@ -0,0 +1,104 @@
+package marvin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStdlibRouter(t *testing.T) {
+	svr := newTestServer(t, testService{
+		router: []RouterOption{RouterSelect("stdlib")},
+		endpoints: map[string]map[string]HTTPEndpoint{
+			"/cat/{id}":             {"GET": varsEndpoint()},
+			"/cat/{name}":           {"PUT": varsEndpoint()},
+			"/cat/new":              {"GET": testEndpoint("new")},
+			"/cat/{id}.json":        {"GET": varsEndpoint()},
+			"/cat/{id:[0-9]+}/toys": {"GET": varsEndpoint()},
+			"/file/{path:.*}":       {"GET": varsEndpoint()},
+			"/box/{a}-{b}":          {"GET": varsEndpoint()},
+		},
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+
+		wantCode int
+		wantBody string
+	}{
+		{name: "variable", method: "GET", path: "/cat/1", wantCode: http.StatusOK, wantBody: `{"id":"1"}`},
+		{name: "same shape", method: "PUT", path: "/cat/1", wantCode: http.StatusOK, wantBody: `{"name":"1"}`},
+		{name: "literal", method: "GET", path: "/cat/new", wantCode: http.StatusOK, wantBody: `"new"`},
+		{name: "partial segment", method: "GET", path: "/cat/1.json", wantCode: http.StatusOK, wantBody: `{"id":"1"}`},
+		{name: "pattern", method: "GET", path: "/cat/1/toys", wantCode: http.StatusOK, wantBody: `{"id":"1"}`},
+		{name: "pattern mismatch", method: "GET", path: "/cat/x/toys", wantCode: http.StatusNotFound},
+		{name: "catch all", method: "GET", path: "/file/a/b", wantCode: http.StatusOK, wantBody: `{"path":"a/b"}`},
+		{name: "two variables", method: "GET", path: "/box/1-2", wantCode: http.StatusOK, wantBody: `{"a":"1","b":"2"}`},
+		{name: "method not allowed", method: "DELETE", path: "/cat/1.json", wantCode: http.StatusMethodNotAllowed},
+		{name: "not found", method: "GET", path: "/dog", wantCode: http.StatusNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := serve(svr, test.method, test.path, "")
+			if w.Code != test.wantCode {
+				t.Fatalf("expected status %d, got %d: %s", test.wantCode, w.Code, w.Body)
+			}
+			if test.wantBody != "" && strings.TrimSpace(w.Body.String()) != test.wantBody {
+				t.Errorf("expected body %s, got %s", test.wantBody, w.Body)
+			}
+		})
+	}
+}
+
+// varsEndpoint responds with the route variables of the request.
+func varsEndpoint() HTTPEndpoint {
+	return HTTPEndpoint{
+		Endpoint: func(_ context.Context, req interface{}) (interface{}, error) {
+			return req, nil
+		},
+		Decoder: func(_ context.Context, r *http.Request) (interface{}, error) {
+			return Vars(r), nil
+		},
+	}
+}
+
+func TestStdlibRouterRoot(t *testing.T) {
+	teapot := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	tests := []struct {
+		name string
+		root bool
+		path string
+
+		wantCode int
+		wantBody string
+	}{
+		{name: "not found", path: "/dog", wantCode: http.StatusTeapot},
+		{name: "regexp", path: "/cat/1.json", wantCode: http.StatusOK, wantBody: "id=1"},
+		{name: "root", root: true, path: "/dog", wantCode: http.StatusOK},
+		{name: "regexp over root", root: true, path: "/cat/1.json", wantCode: http.StatusOK, wantBody: "id=1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := NewStdlibRouter()
+			router.SetNotFoundHandler(teapot)
+			router.Handle(http.MethodGet, "/cat/{id}.json", varsHandler)
+			if test.root {
+				router.Handle(http.MethodGet, "/", varsHandler)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+			if w.Code != test.wantCode {
+				t.Fatalf("expected status %d, got %d", test.wantCode, w.Code)
+			}
+			if w.Body.String() != test.wantBody {
+				t.Errorf("expected body %q, got %q", test.wantBody, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+//go:build go1.22
+// +build go1.22
+
+package marvin
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// muxWildcards is false if the ServeMux runs in its pre-1.22 mode, like it does
+// with GODEBUG=httpmuxgo121=1 or for modules that declare an older Go version.
+var muxWildcards = func() bool {
+	mux := http.NewServeMux()
+	mux.Handle("/{x}", http.NotFoundHandler())
+	_, pattern := mux.Handler(&http.Request{Method: http.MethodGet, URL: &url.URL{Path: "/x"}})
+	return pattern != ""
+}()
+
+// stdlibPattern will translate a Gorilla style path template into a ServeMux
+// pattern with wildcards. The wildcards are named after their segment, so paths
+// of the same shape, like `/cat/{id}` and `/cat/{name}`, share a pattern. It
+// returns false if the ServeMux cannot express the path's variables, like ones
+// that do not span a whole segment. Paths that cannot be parsed are used as
+// they are.
+func stdlibPattern(path string) (string, []stdlibVar, bool) {
+	parts, err := parsePath(path)
+	if err != nil {
+		return path, nil, true
+	}
+	var (
+		pattern string
+		vars    []stdlibVar
+	)
+	for i, p := range parts {
+		if !p.isVar() {
+			pattern += p.literal
+			continue
+		}
+		if !muxWildcards || !strings.HasSuffix(pattern, "/") {
+			return "", nil, false
+		}
+		last := i == len(parts)-1
+		if !last && !strings.HasPrefix(parts[i+1].literal, "/") {
+			return "", nil, false
+		}
+		v := stdlibVar{name: p.name, wildcard: "v" + strconv.Itoa(strings.Count(pattern, "/")-1)}
+		switch {
+		case last && (p.pattern == ".*" || p.pattern == ".+"):
+			pattern += "{" + v.wildcard + "...}"
+			if p.pattern == ".+" {
+				v.pattern = regexp.MustCompile(`^.+$`)
+			}
+		case p.pattern != "":
+			v.pattern = regexp.MustCompile("^(?:" + p.pattern + ")$")
+			if v.pattern.MatchString("a/b") {
+				return "", nil, false
+			}
+			pattern += "{" + v.wildcard + "}"
+		default:
+			pattern += "{" + v.wildcard + "}"
+		}
+		vars = append(vars, v)
+	}
+	return pattern, vars, true
+}
+
+// stdlibVars will pull the route's variables out of the request. It returns false
+// if any of them do not match their pattern.
+func stdlibVars(r *http.Request, vars []stdlibVar) (map[string]string, bool) {
+	if len(vars) == 0 {
+		return nil, true
+	}
+	out := make(map[string]string, len(vars))
+	for _, v := range vars {
+		val := r.PathValue(v.wildcard)
+		if v.pattern != nil && !v.pattern.MatchString(val) {
+			return nil, false
+		}
+		out[v.name] = val
+	}
+	return out, true
+}
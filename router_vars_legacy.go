@@ -0,0 +1,26 @@
+//go:build !go1.22
+// +build !go1.22
+
+package marvin
+
+import "net/http"
+
+// stdlibPattern returns the path as it is. Before Go 1.22, the ServeMux does not
+// support path variables, so it returns false for paths that have them.
+func stdlibPattern(path string) (string, []stdlibVar, bool) {
+	parts, err := parsePath(path)
+	if err != nil {
+		return path, nil, true
+	}
+	for _, p := range parts {
+		if p.isVar() {
+			return "", nil, false
+		}
+	}
+	return path, nil, true
+}
+
+// stdlibVars returns no variables.
+func stdlibVars(_ *http.Request, _ []stdlibVar) (map[string]string, bool) {
+	return nil, true
+}
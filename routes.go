@@ -0,0 +1,75 @@
+package marvin
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+)
+
+// Route describes a route registered with a Server.
+type Route struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Format is the format the route is served in.
+	Format Format `json:"format,omitempty"`
+	// Protocol is one of "http", "twirp" or "grpc-web".
+	Protocol string `json:"protocol"`
+	// Version is the version of the Group the route belongs to.
+	Version string `json:"version,omitempty"`
+	// Implicit is true for routes marvin adds on behalf of the service,
+	// like /_ah/warmup.
+	Implicit bool `json:"implicit,omitempty"`
+}
+
+// routeList collects the routes of a Server as they are registered.
+type routeList struct {
+	routes []Route
+}
+
+func (l *routeList) add(r Route) {
+	l.routes = append(l.routes, r)
+}
+
+// Routes returns every route registered with the Server, in the order they
+// were registered.
+func (s Server) Routes() []Route {
+	if s.routes == nil {
+		return nil
+	}
+	return append([]Route{}, s.routes.routes...)
+}
+
+const routesURI = "/_marvin/routes"
+
+// ExposeRoutes is a server option that will serve the routes of the Server as JSON
+// at /_marvin/routes for debugging and diffing deployments. The route is wrapped
+// with the given guards, the first being the outermost. Without any guards, it is
+// marked as Internal, so it is only available to other App Engine services in the
+// same application. Internal denies every request outside of App Engine, so
+// services that Run elsewhere should pass their own authentication middleware.
+func ExposeRoutes(guards ...endpoint.Middleware) ServerOption {
+	return func(s *Server) {
+		s.exposeRoutes = true
+		s.routesGuards = guards
+	}
+}
+
+// routesHandler returns the handler for /_marvin/routes.
+func (s Server) routesHandler() http.Handler {
+	var ep endpoint.Endpoint = func(context.Context, interface{}) (interface{}, error) {
+		return s.Routes(), nil
+	}
+	if len(s.routesGuards) == 0 {
+		ep = Internal(ep, nil)
+	}
+	for i := len(s.routesGuards) - 1; i >= 0; i-- {
+		ep = s.routesGuards[i](ep)
+	}
+	return httptransport.NewServer(
+		ep,
+		func(context.Context, *http.Request) (interface{}, error) { return nil, nil },
+		EncodeJSONResponse,
+		formatOpts(FormatJSON)...)
+}
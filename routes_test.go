@@ -0,0 +1,60 @@
+package marvin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+func TestExposeRoutes(t *testing.T) {
+	allow := func(ep endpoint.Endpoint) endpoint.Endpoint { return ep }
+	deny := func(endpoint.Endpoint) endpoint.Endpoint {
+		return func(context.Context, interface{}) (interface{}, error) {
+			return nil, NewError(CodePermissionDenied, "no routes for you")
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts []ServerOption
+
+		wantCode int
+	}{
+		{name: "not exposed", wantCode: http.StatusNotFound},
+		{name: "internal outside of App Engine", opts: []ServerOption{ExposeRoutes()}, wantCode: http.StatusUnauthorized},
+		{name: "guard", opts: []ServerOption{ExposeRoutes(allow)}, wantCode: http.StatusOK},
+		{name: "denying guard", opts: []ServerOption{ExposeRoutes(allow, deny)}, wantCode: http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svr := newTestServer(t, testService{endpoints: map[string]map[string]HTTPEndpoint{
+				"/cat": {"GET": testEndpoint("cat")},
+			}}, test.opts...)
+			w := serve(svr, http.MethodGet, routesURI, "")
+			if w.Code != test.wantCode {
+				t.Fatalf("expected status %d, got %d: %s", test.wantCode, w.Code, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var routes []Route
+			if err := json.Unmarshal(w.Body.Bytes(), &routes); err != nil {
+				t.Fatalf("unable to parse routes: %s", err)
+			}
+			want := Route{Method: "GET", Path: "/cat", Format: FormatJSON, Protocol: "http"}
+			if len(routes) == 0 || routes[0] != want {
+				t.Errorf("expected the first route to be %+v, got %+v", want, routes)
+			}
+			var warmup bool
+			for _, r := range routes {
+				warmup = warmup || r.Path == warmupURI && r.Implicit
+			}
+			if !warmup {
+				t.Errorf("expected an implicit warmup route, got %+v", routes)
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package marvin
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"sort"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+// RPCService maps the methods of a Protobuf `service` definition onto marvin
+// endpoints so they can be exposed via Twirp or gRPC-Web. For the
+// reading-list example's ReadingListService, that would look like:
+//
+//	return marvin.RPCService{
+//		Package: "readinglist",
+//		Name:    "ReadingListService",
+//		Methods: map[string]marvin.RPCMethod{
+//			"PutLinkProtoJSON": {
+//				Endpoint:   s.putLink,
+//				NewRequest: func() proto.Message { return &PutLinkProtoJSONRequest{} },
+//			},
+//			"GetListProtoJSON": {
+//				Endpoint:   s.getLinks,
+//				NewRequest: func() proto.Message { return &GetListProtoJSONRequest{} },
+//			},
+//		},
+//	}
+type RPCService struct {
+	// Package is the Protobuf package the service is declared in.
+	Package string
+	// Name is the name of the Protobuf service.
+	Name string
+	// Methods maps the RPC method names to their endpoints.
+	Methods map[string]RPCMethod
+}
+
+// RPCMethod is a single method of an RPCService. The Endpoint will receive the
+// message from NewRequest and should return the method's output message. Like
+// all other endpoints, it will be wrapped with the Service's Middleware.
+type RPCMethod struct {
+	Endpoint endpoint.Endpoint
+	// NewRequest returns an empty input message for the method.
+	NewRequest ProtoFactory
+	Options    []httptransport.ServerOption
+
+	// Middleware, HTTPMiddleware and SkipServiceMiddleware behave just like
+	// they do for an HTTPEndpoint.
+	Middleware            []endpoint.Middleware
+	HTTPMiddleware        []func(http.Handler) http.Handler
+	SkipServiceMiddleware bool
+}
+
+// handler will build the http.Handler for the method with the given request
+// decoder and response encoder and all of its middlewares applied.
+func (m RPCMethod) handler(svc Service, dec httptransport.DecodeRequestFunc,
+	enc httptransport.EncodeResponseFunc, opts []httptransport.ServerOption) http.Handler {
+	return HTTPEndpoint{
+		Endpoint:              m.Endpoint,
+		Decoder:               dec,
+		Encoder:               enc,
+		Middleware:            m.Middleware,
+		HTTPMiddleware:        m.HTTPMiddleware,
+		SkipServiceMiddleware: m.SkipServiceMiddleware,
+	}.handler(svc, opts)
+}
+
+// fullName returns the fully qualified Protobuf name of the service.
+func (s RPCService) fullName() string {
+	if s.Package == "" {
+		return s.Name
+	}
+	return s.Package + "." + s.Name
+}
+
+// methodNames returns the names of the service's methods in sorted order.
+func (s RPCService) methodNames() []string {
+	names := make([]string, 0, len(s.Methods))
+	for name := range s.Methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// check will add any methods that cannot be registered under the given path
+// prefix to the RegistrationError.
+func (s RPCService) check(prefix string, rerr *RegistrationError) {
+	for _, name := range s.methodNames() {
+		m := s.Methods[name]
+		switch {
+		case m.Endpoint == nil:
+			rerr.add(http.MethodPost, prefix+s.fullName()+"/"+name, "missing Endpoint")
+		case m.NewRequest == nil:
+			rerr.add(http.MethodPost, prefix+s.fullName()+"/"+name, "missing NewRequest")
+		}
+	}
+}
+
+// TwirpEndpointer can be implemented by a Service to expose an RPCService via the
+// Twirp protocol. Each method will be served at
+// `POST /twirp/<package>.<Service>/<Method>` and accept 'application/protobuf' or
+// 'application/json' requests. JSON responses use the original field names from
+// the .proto file, like Twirp's own servers. Errors, including requests with any
+// other method or content type, will be returned in Twirp's JSON error envelope
+// with the Twirp error code that matches their status code.
+type TwirpEndpointer interface {
+	TwirpService() RPCService
+}
+
+// registerTwirp will register all the methods of the RPCService as Twirp routes.
+func (s Server) registerTwirp(svc Service, rpc RPCService) {
+	for _, name := range rpc.methodNames() {
+		m := rpc.Methods[name]
+		opts := append([]httptransport.ServerOption{}, defaultOpts...)
+		opts = append(opts, httptransport.ServerBefore(
+			func(ctx context.Context, r *http.Request) context.Context {
+				return context.WithValue(ctx, ContextKeyFormat, RequestFormat(r))
+			}))
+		opts = append(opts, svc.Options()...)
+		opts = append(opts, m.Options...)
+		// Twirp clients require Twirp errors
+		opts = append(opts, httptransport.ServerErrorEncoder(encodeTwirpError))
+
+		path := "/twirp/" + rpc.fullName() + "/" + name
+		s.handle(http.MethodPost, path,
+			m.handler(svc, decodeTwirpRequest(m.NewRequest), encodeTwirpResponse, opts))
+		for _, method := range twirpBadMethods {
+			s.route(method, path, twirpBadMethod(path))
+		}
+		s.routes.add(Route{
+			Method:   http.MethodPost,
+			Path:     path,
+			Format:   FormatNegotiated,
+			Protocol: "twirp",
+		})
+	}
+}
+
+// twirpBadMethods are the methods that receive a bad_route error from Twirp
+// routes instead of a plain 405, since Twirp clients expect its error envelope.
+var twirpBadMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// twirpBadMethod responds to requests for a Twirp route that do not use POST.
+func twirpBadMethod(path string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodeTwirpError(r.Context(), twirpError{
+			Code: "bad_route",
+			Msg:  "unsupported method " + r.Method + " (only POST is allowed)",
+			Meta: map[string]string{"twirp_invalid_route": r.Method + " " + path},
+		}, w)
+	})
+}
+
+func decodeTwirpRequest(newMsg ProtoFactory) httptransport.DecodeRequestFunc {
+	dec := DecodeRequest(newMsg, AllowEmptyBody())
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		// Twirp only allows these exact media types
+		mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mt != "application/json" && mt != "application/protobuf" {
+			return nil, twirpError{Code: "bad_route",
+				Msg: "unexpected Content-Type: " + r.Header.Get("Content-Type")}
+		}
+		req, err := dec(ctx, r)
+		if err != nil {
+			return nil, twirpError{Code: "malformed", Msg: errorMessage(err)}
+		}
+		return req, nil
+	}
+}
+
+func encodeTwirpResponse(ctx context.Context, w http.ResponseWriter, res interface{}) error {
+	// some middlewares, like AllowIPNets, deny requests with a response
+	if sc, ok := res.(httptransport.StatusCoder); ok && sc.StatusCode() >= http.StatusBadRequest {
+		if err, ok := res.(error); ok {
+			encodeTwirpError(ctx, err, w)
+			return nil
+		}
+	}
+	var (
+		b   []byte
+		err error
+	)
+	if ContextFormat(ctx) == FormatProto {
+		msg, ok := res.(proto.Message)
+		if !ok {
+			return errors.New("response does not implement proto.Message")
+		}
+		w.Header().Set("Content-Type", "application/protobuf")
+		b, err = proto.Marshal(msg)
+	} else {
+		// Twirp clients expect the original .proto field names
+		codec := DefaultJSONCodec
+		codec.OrigName = true
+		w.Header().Set("Content-Type", "application/json")
+		b, err = codec.Marshal(res)
+	}
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(b)
+	return err
+}
+
+// twirpError is the JSON error envelope of the Twirp protocol.
+type twirpError struct {
+	Code string            `json:"code"`
+	Msg  string            `json:"msg"`
+	Meta map[string]string `json:"meta,omitempty"`
+}
+
+func (t twirpError) Error() string {
+	return t.Code + ": " + t.Msg
+}
+
+// twirpStatus maps the Twirp error codes to their HTTP status codes.
+var twirpStatus = map[string]int{
+	"canceled":            http.StatusRequestTimeout,
+	"unknown":             http.StatusInternalServerError,
+	"invalid_argument":    http.StatusBadRequest,
+	"malformed":           http.StatusBadRequest,
+	"deadline_exceeded":   http.StatusRequestTimeout,
+	"not_found":           http.StatusNotFound,
+	"bad_route":           http.StatusNotFound,
+	"already_exists":      http.StatusConflict,
+	"permission_denied":   http.StatusForbidden,
+	"unauthenticated":     http.StatusUnauthorized,
+	"resource_exhausted":  http.StatusTooManyRequests,
+	"failed_precondition": http.StatusPreconditionFailed,
+	"aborted":             http.StatusConflict,
+	"out_of_range":        http.StatusBadRequest,
+	"unimplemented":       http.StatusNotImplemented,
+	"internal":            http.StatusInternalServerError,
+	"unavailable":         http.StatusServiceUnavailable,
+	"data_loss":           http.StatusInternalServerError,
+}
+
+// encodeTwirpError is an httptransport.ErrorEncoder that responds with Twirp's
+// JSON error envelope.
+func encodeTwirpError(ctx context.Context, err error, w http.ResponseWriter) {
+	te, ok := err.(twirpError)
+	if !ok {
+		e := toError(err)
+		if e.cause != nil {
+			logErrorf(ctx, "error serving request: %+v", err)
+		}
+		te = twirpError{Code: e.Code.String(), Msg: e.Message, Meta: e.Details}
+	}
+	b, _ := json.Marshal(te)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(twirpStatus[te.Code])
+	w.Write(b)
+}
+
+// errorMessage will pull a human readable message out of an error. Status
+// responses that wrap a message with a GetMessage() method, like ErrorMessage,
+// will use that message.
+func errorMessage(err error) string {
+	var res interface{}
+	switch e := err.(type) {
+	case *ProblemResponse:
+		if e.Detail != "" {
+			return e.Detail
+		}
+		return e.Title
+	case *ProtoStatusResponse:
+		res = e.res
+	case *JSONStatusResponse:
+		res = e.res
+	}
+	switch r := res.(type) {
+	case interface {
+		GetMessage() string
+	}:
+		if msg := r.GetMessage(); msg != "" {
+			return msg
+		}
+	case string:
+		return r
+	case map[string]string:
+		for _, k := range []string{"message", "msg"} {
+			if msg, ok := r[k]; ok {
+				return msg
+			}
+		}
+	}
+	return err.Error()
+}
@@ -0,0 +1,204 @@
+package marvin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/golang/protobuf/proto"
+)
+
+// testRPCCat is a minimal Protobuf message for the RPC tests.
+type testRPCCat struct {
+	CatName string `protobuf:"bytes,1,opt,name=cat_name,json=catName" json:"cat_name,omitempty"`
+}
+
+func (m *testRPCCat) Reset()         { *m = testRPCCat{} }
+func (m *testRPCCat) String() string { return proto.CompactTextString(m) }
+func (*testRPCCat) ProtoMessage()    {}
+
+// twirpService serves its RPCService via Twirp and denies every request in its
+// service Middleware.
+type twirpService struct {
+	testService
+	rpc RPCService
+}
+
+func (s twirpService) Middleware(endpoint.Endpoint) endpoint.Endpoint {
+	return func(context.Context, interface{}) (interface{}, error) {
+		return nil, NewError(CodePermissionDenied, "no cats allowed")
+	}
+}
+
+func (s twirpService) TwirpService() RPCService { return s.rpc }
+
+// renameCat responds with the cat it receives under a new name.
+func renameCat(name string) endpoint.Endpoint {
+	return func(_ context.Context, req interface{}) (interface{}, error) {
+		cat := *req.(*testRPCCat)
+		cat.CatName += name
+		return &cat, nil
+	}
+}
+
+func TestTwirp(t *testing.T) {
+	newCat := func() proto.Message { return &testRPCCat{} }
+	svr := newTestServer(t, twirpService{rpc: RPCService{
+		Package: "cats",
+		Name:    "CatService",
+		Methods: map[string]RPCMethod{
+			"Rename": {
+				Endpoint:              renameCat("!"),
+				NewRequest:            newCat,
+				SkipServiceMiddleware: true,
+				Middleware: []endpoint.Middleware{func(ep endpoint.Endpoint) endpoint.Endpoint {
+					return func(ctx context.Context, req interface{}) (interface{}, error) {
+						req.(*testRPCCat).CatName += " Jr."
+						return ep(ctx, req)
+					}
+				}},
+			},
+			"Adopt": {
+				Endpoint:   renameCat("!"),
+				NewRequest: newCat,
+			},
+		},
+	}})
+	protoCat, err := proto.Marshal(&testRPCCat{CatName: "Tom"})
+	if err != nil {
+		t.Fatalf("unable to marshal cat: %s", err)
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+
+		wantCode        int
+		wantContentType string
+		wantCat         string
+		wantError       string
+	}{
+		{
+			name:            "json",
+			method:          http.MethodPost,
+			path:            "/twirp/cats.CatService/Rename",
+			contentType:     "application/json",
+			body:            `{"catName":"Tom"}`,
+			wantCode:        http.StatusOK,
+			wantContentType: "application/json",
+			wantCat:         "Tom Jr.!",
+		},
+		{
+			name:            "proto",
+			method:          http.MethodPost,
+			path:            "/twirp/cats.CatService/Rename",
+			contentType:     "application/protobuf",
+			body:            string(protoCat),
+			wantCode:        http.StatusOK,
+			wantContentType: "application/protobuf",
+			wantCat:         "Tom Jr.!",
+		},
+		{
+			name:            "service middleware",
+			method:          http.MethodPost,
+			path:            "/twirp/cats.CatService/Adopt",
+			contentType:     "application/json",
+			body:            `{"cat_name":"Tom"}`,
+			wantCode:        http.StatusForbidden,
+			wantContentType: "application/json",
+			wantError:       "permission_denied",
+		},
+		{
+			name:            "get",
+			method:          http.MethodGet,
+			path:            "/twirp/cats.CatService/Rename",
+			wantCode:        http.StatusNotFound,
+			wantContentType: "application/json",
+			wantError:       "bad_route",
+		},
+		{
+			name:            "put",
+			method:          http.MethodPut,
+			path:            "/twirp/cats.CatService/Rename",
+			contentType:     "application/json",
+			body:            `{"cat_name":"Tom"}`,
+			wantCode:        http.StatusNotFound,
+			wantContentType: "application/json",
+			wantError:       "bad_route",
+		},
+		{
+			name:            "json suffix",
+			method:          http.MethodPost,
+			path:            "/twirp/cats.CatService/Rename",
+			contentType:     "application/problem+json",
+			body:            `{"cat_name":"Tom"}`,
+			wantCode:        http.StatusNotFound,
+			wantContentType: "application/json",
+			wantError:       "bad_route",
+		},
+		{
+			name:            "x-protobuf",
+			method:          http.MethodPost,
+			path:            "/twirp/cats.CatService/Rename",
+			contentType:     "application/x-protobuf",
+			body:            string(protoCat),
+			wantCode:        http.StatusNotFound,
+			wantContentType: "application/json",
+			wantError:       "bad_route",
+		},
+		{
+			name:            "malformed",
+			method:          http.MethodPost,
+			path:            "/twirp/cats.CatService/Rename",
+			contentType:     "application/json",
+			body:            `{"cat_name":`,
+			wantCode:        http.StatusBadRequest,
+			wantContentType: "application/json",
+			wantError:       "malformed",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := serve(svr, test.method, test.path, test.body, "Content-Type", test.contentType)
+			if w.Code != test.wantCode {
+				t.Fatalf("expected status %d, got %d: %s", test.wantCode, w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("Content-Type"); got != test.wantContentType {
+				t.Errorf("expected content type %q, got %q", test.wantContentType, got)
+			}
+			if test.wantError != "" {
+				var te twirpError
+				if err := json.Unmarshal(w.Body.Bytes(), &te); err != nil {
+					t.Fatalf("unable to parse error %q: %s", w.Body.String(), err)
+				}
+				if te.Code != test.wantError {
+					t.Errorf("expected error code %q, got %q", test.wantError, te.Code)
+				}
+				return
+			}
+			var (
+				cat testRPCCat
+				err error
+			)
+			if test.wantContentType == "application/protobuf" {
+				err = proto.Unmarshal(w.Body.Bytes(), &cat)
+			} else {
+				// Twirp uses the original field names
+				var raw map[string]string
+				err = json.Unmarshal(w.Body.Bytes(), &raw)
+				cat.CatName = raw["cat_name"]
+			}
+			if err != nil {
+				t.Fatalf("unable to parse response %q: %s", w.Body.String(), err)
+			}
+			if cat.CatName != test.wantCat {
+				t.Errorf("expected cat %q, got %q", test.wantCat, cat.CatName)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package marvin
+
+import (
+	"context"
+	stdlog "log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// DefaultShutdownTimeout is the time Run gives in-flight requests to complete
+// after it receives a SIGTERM. Server.Shutdown gives the OnShutdown hooks the same
+// time again once the requests are done.
+const DefaultShutdownTimeout = 10 * time.Second
+
+// Run will register the Service with a Server and serve it with a plain net/http
+// server on $PORT, or 8080 if it is not set. Unlike Init, Run does not need the
+// App Engine API: requests get the StandardContext unless the BaseContext option
+// is given, so the same Service can run on newer App Engine runtimes, Cloud Run or
+// a laptop. Errors marvin logs itself go to the standard logger.
+//
+// Run blocks until the process receives a SIGTERM or an interrupt. It will then
+// flag the instance as Stopping, stop accepting new connections and wait up to
+// DefaultShutdownTimeout for in-flight requests. If the Service is a Stopper, it
+// is stopped within the stop timeout before the Server's OnShutdown hooks run and
+// Run returns.
+func Run(service Service, opts ...ServerOption) error {
+	opts = append([]ServerOption{BaseContext(StandardContext)}, opts...)
+	svr, err := NewServerE(service, opts...)
+	if err != nil {
+		return err
+	}
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	l, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return err
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(sigs)
+
+	return run(svr, service, l, sigs)
+}
+
+// run will serve the Server on the listener until a signal is received and then
+// shut it down.
+func run(svr Server, service Service, l net.Listener, sigs <-chan os.Signal) error {
+	hs := &http.Server{Handler: svr}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- hs.Serve(l)
+	}()
+	stdlog.Printf("marvin: listening on %s", l.Addr())
+
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-sigs:
+		stdlog.Printf("marvin: received %s, shutting down", sig)
+	}
+	svr.shutdown.stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultShutdownTimeout)
+	defer cancel()
+	err := hs.Shutdown(ctx)
+
+	if st, ok := service.(Stopper); ok {
+		timeout := svr.stopTimeout
+		if timeout <= 0 {
+			timeout = DefaultStopTimeout
+		}
+		sctx, scancel := context.WithTimeout(context.Background(), timeout)
+		defer scancel()
+		if serr := st.Stop(sctx); err == nil {
+			err = serr
+		}
+	}
+
+	// the requests are done, so the Server only has to run its hooks
+	if serr := svr.Shutdown(context.Background()); err == nil {
+		err = serr
+	}
+	return err
+}
@@ -0,0 +1,110 @@
+package marvin
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// runService is a testService that implements Stopper.
+type runService struct {
+	testService
+	stopErr error
+	stopped chan struct{}
+}
+
+func (s runService) Stop(ctx context.Context) error {
+	close(s.stopped)
+	return s.stopErr
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		stopErr error
+		hookErr error
+
+		wantErr error
+	}{
+		{name: "clean"},
+		{name: "stop error", stopErr: errors.New("queue is stuck"), wantErr: errors.New("queue is stuck")},
+		{name: "hook error", hookErr: errors.New("logs are stuck"), wantErr: errors.New("logs are stuck")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svc := runService{
+				testService: testService{endpoints: map[string]map[string]HTTPEndpoint{
+					"/cat": {"GET": testEndpoint("cat")},
+				}},
+				stopErr: test.stopErr,
+				stopped: make(chan struct{}),
+			}
+			hooked := make(chan struct{})
+			svr := newTestServer(t, svc, OnShutdown(func(ctx context.Context) error {
+				defer close(hooked)
+				select {
+				case <-svc.stopped:
+				default:
+					t.Error("expected the service to stop before the hooks run")
+				}
+				if ctx.Err() != nil {
+					t.Errorf("expected the hooks to get a live context, got %s", ctx.Err())
+				}
+				return test.hookErr
+			}))
+
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("unable to listen: %s", err)
+			}
+			sigs := make(chan os.Signal, 1)
+			errs := make(chan error, 1)
+			go func() {
+				errs <- run(svr, svc, l, sigs)
+			}()
+
+			res, err := http.Get("http://" + l.Addr().String() + "/cat")
+			if err != nil {
+				t.Fatalf("unable to reach server: %s", err)
+			}
+			res.Body.Close()
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("expected status 200, got %d", res.StatusCode)
+			}
+
+			sigs <- syscall.SIGTERM
+			select {
+			case err = <-errs:
+			case <-time.After(time.Second):
+				t.Fatal("run did not return after a signal")
+			}
+			if (err == nil) != (test.wantErr == nil) || err != nil && err.Error() != test.wantErr.Error() {
+				t.Errorf("expected error %v, got %v", test.wantErr, err)
+			}
+			select {
+			case <-hooked:
+			default:
+				t.Error("expected the OnShutdown hook to run")
+			}
+		})
+	}
+}
+
+func TestRunListenerError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %s", err)
+	}
+	l.Close()
+	svc := testService{endpoints: map[string]map[string]HTTPEndpoint{
+		"/cat": {"GET": testEndpoint("cat")},
+	}}
+	if err := run(newTestServer(t, svc), svc, l, make(chan os.Signal)); err == nil {
+		t.Error("expected an error for a closed listener")
+	}
+}
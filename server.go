@@ -2,13 +2,15 @@ package marvin // import "github.com/NYTimes/marvin"
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"net/http"
+	"sort"
+	"time"
 
+	"github.com/go-kit/kit/endpoint"
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/golang/protobuf/proto"
-
-	"github.com/NYTimes/marvin/internal"
+	"github.com/pkg/errors"
 )
 
 type contextKey int
@@ -17,9 +19,18 @@ const (
 	// ContextKeyInboundAppID is populated in the context by default.
 	// It contains the value of the 'X-Appengine-Inbound-Appid' header.
 	ContextKeyInboundAppID contextKey = iota
+	// ContextKeyFormat is populated in the context by default.
+	// It contains the response Format selected for the request.
+	ContextKeyFormat
 	// key to set/retrieve URL params from a
 	// Gorilla request context.
 	varsKey
+	// key to flag requests that should be
+	// answered with problem documents.
+	problemsKey
+	// key to retrieve the shutdown state of
+	// the Server handling the request.
+	shutdownKey
 )
 
 var defaultOpts = []httptransport.ServerOption{
@@ -31,15 +42,20 @@ var defaultOpts = []httptransport.ServerOption{
 		},
 		// populate context with helpful keys
 		httptransport.PopulateRequestContext),
+	httptransport.ServerErrorEncoder(EncodeError),
 }
 
 // Init will register the Service with a Server
 // and register the server with App Engine.
 // Call this in an `init()` or `main()` function.
-func Init(service Service) {
-	http.Handle("/", NewServer(service))
+// Use Run to serve the Service outside of the App Engine standard sandbox.
+func Init(service Service, opts ...ServerOption) {
+	http.Handle("/", NewServer(service, append(opts, appEngineLifecycle)...))
 }
 
+// ServerOption sets optional Server overrides.
+type ServerOption func(*Server)
+
 // Server manages routing and initiating the request context.
 // Users should only need to interact with this struct in testing.
 //
@@ -47,124 +63,350 @@ func Init(service Service) {
 type Server struct {
 	mux Router
 	svc Service
+
+	// builds the base context of each request
+	newContext ContextProvider
+
+	// every path and method registered with the router
+	methods          *methodTable
+	methodNotAllowed http.Handler
+
+	routes       *routeList
+	exposeRoutes bool
+	routesGuards []endpoint.Middleware
+
+	warmupTimeout time.Duration
+	stopTimeout   time.Duration
+
+	healthPath     string
+	readyPath      string
+	healthCacheTTL time.Duration
+
+	shutdown *shutdownState
+	// set by Init so App Engine's stop requests can shut the Server down
+	lifecycle bool
+
+	// collects the routes the Router refuses while registering the service
+	routeErrs *RegistrationError
 }
 
 // NewServer will init the mux and register all endpoints.
 // This gets called by Init() and should only be used within
-// tests.
+// tests. It will panic if any endpoint cannot be registered.
 //
 // See examples/reading-list/api/service_test.go for example usage.
-func NewServer(svc Service) Server {
-	opts := svc.RouterOptions()
-	if len(opts) == 0 {
+func NewServer(svc Service, opts ...ServerOption) Server {
+	svr, err := NewServerE(svc, opts...)
+	if err != nil {
+		panic("unable to register service: " + err.Error())
+	}
+	return svr
+}
+
+// NewServerE will init the mux and register all endpoints in a deterministic
+// order, with more specific paths first. If any endpoints are invalid or
+// conflict with each other, nothing is registered and a *RegistrationError
+// listing all of them is returned. Paths the Router refuses, like `/cat/{id}`
+// next to `/cat/new` with httprouter, are listed the same way.
+func NewServerE(svc Service, opts ...ServerOption) (Server, error) {
+	ropts := svc.RouterOptions()
+	if len(ropts) == 0 {
 		// select the default router
-		opts = append(opts, RouterSelect(""))
+		ropts = append(ropts, RouterSelect(""))
 	}
 	var r Router
-	for _, opt := range opts {
+	for _, opt := range ropts {
 		r = opt(r)
 	}
 	svr := Server{
-		mux: r,
-		svc: svc,
+		mux:        r,
+		svc:        svc,
+		methods:    newMethodTable(),
+		routes:     &routeList{},
+		healthPath: DefaultHealthPath,
+		readyPath:  DefaultReadyPath,
+		shutdown:   newShutdownState(),
 	}
-	err := svr.register(svc)
-	if err != nil {
-		panic("unable to register service: " + err.Error())
+	for _, opt := range opts {
+		opt(&svr)
 	}
-
-	return svr
+	if ur, ok := r.(unmatchedRouter); ok {
+		ur.setUnmatched(svr.answerMethods)
+	}
+	if err := svr.register(svc); err != nil {
+		return Server{}, err
+	}
+	return svr, nil
 }
 
 // ServeHTTP is the entrypoint for the server. This will initiate
-// the app engine context and hand the request off to the router.
+// the request context with the ContextProvider and hand the request off
+// to the router.
+// OPTIONS, HEAD and requests with methods that have no endpoint are
+// answered by the Server instead of the router.
 func (s Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	r = r.WithContext(internal.NewContext(r))
-	s.svc.HTTPMiddleware(s.mux).ServeHTTP(w, r)
+	r = r.WithContext(context.WithValue(s.baseContext(r), shutdownKey, s.shutdown))
+	s.svc.HTTPMiddleware(s.handleMethods(s.mux)).ServeHTTP(w, r)
+}
+
+// handle will register the handler for OPTIONS, HEAD and 405 handling and
+// track its requests for graceful shutdowns.
+func (s Server) handle(method, path string, h http.Handler) {
+	s.route(method, path, s.track(h))
+}
+
+// handleImplicit will register a route that marvin adds on behalf of the service.
+// Requests to these routes are not tracked, so they are still served while the
+// Server shuts down.
+func (s Server) handleImplicit(method, path string, f Format, h http.Handler) {
+	s.route(method, path, h)
+	s.routes.add(Route{Method: method, Path: path, Format: f, Protocol: "http", Implicit: true})
+}
+
+// route will register the handler with the router and record the path and method.
+// Routers panic on paths they cannot serve, like ones that conflict with another
+// path in httprouter, so those are collected as route errors instead.
+func (s Server) route(method, path string, h http.Handler) {
+	defer func() {
+		if p := recover(); p != nil {
+			s.routeErrs.add(method, path, fmt.Sprint(p))
+		}
+	}()
+	s.mux.Handle(method, path, h)
+	s.methods.add(method, path)
+}
+
+// endpointSet is a map of endpoints along with the Format they are served in
+// and the version of the Group they belong to.
+type endpointSet struct {
+	format    Format
+	endpoints map[string]map[string]HTTPEndpoint
+	version   string
 }
 
-// register will accept and register server, JSONService or MixedService implementations.
+// endpointSets will collect all of the endpoint maps the given service exposes,
+// including the endpoints of any groups.
+func endpointSets(svc interface{}) []endpointSet {
+	var sets []endpointSet
+	if je, ok := svc.(JSONEndpointer); ok {
+		sets = append(sets, endpointSet{format: FormatJSON, endpoints: je.JSONEndpoints()})
+	}
+	if pe, ok := svc.(ProtoEndpointer); ok {
+		sets = append(sets, endpointSet{format: FormatProto, endpoints: pe.ProtoEndpoints()})
+	}
+	if ne, ok := svc.(NegotiatedEndpointer); ok {
+		sets = append(sets, endpointSet{format: FormatNegotiated, endpoints: ne.NegotiatedEndpoints()})
+	}
+	if g, ok := svc.(Grouper); ok {
+		for _, group := range g.Groups() {
+			sets = append(sets, group.sets()...)
+		}
+	}
+	return sets
+}
+
+// register will accept and register JSONService, ProtoService, MixedService or
+// NegotiatedService implementations along with any Groups they expose. It will
+// return a *RegistrationError if any of the endpoints are invalid, registered
+// more than once, ambiguous with another path or refused by the Router.
 func (s Server) register(svc Service) error {
-	var (
-		jseps map[string]map[string]HTTPEndpoint
-		peps  map[string]map[string]HTTPEndpoint
-	)
-	switch svc.(type) {
-	case MixedService:
-		jseps = svc.(JSONService).JSONEndpoints()
-		peps = svc.(ProtoService).ProtoEndpoints()
-	case JSONService:
-		jseps = svc.(JSONService).JSONEndpoints()
-	case ProtoService:
-		peps = svc.(ProtoService).ProtoEndpoints()
-	default:
-		return errors.New("services for servers must implement one of the Service interface extensions")
+	sets := endpointSets(svc)
+	var twirp, grpcWeb RPCService
+	te, isTwirp := svc.(TwirpEndpointer)
+	if isTwirp {
+		twirp = te.TwirpService()
+	}
+	ge, isGRPCWeb := svc.(GRPCWebEndpointer)
+	if isGRPCWeb {
+		grpcWeb = ge.GRPCWebService()
+	}
+	if len(sets) == 0 && !isTwirp && !isGRPCWeb {
+		return &RegistrationError{Routes: []RouteError{{
+			Reason: "services for servers must implement one of the Service interface extensions",
+		}}}
 	}
+	var rerr RegistrationError
+	s.routeErrs = &rerr
 
-	opts := defaultOpts
-	opts = append(opts, svc.Options()...)
+	// so we can add a /_ah/warmup, /_ah/start and /_ah/stop if none provided
+	var warmupExists, startExists, stopExists, openAPIExists, routesExists bool
+	var healthExists, readyExists bool
 
-	// so we can add a /_ah/warmup if none provided
-	var warmupExists bool
+	// build all endpoints with our wrappers & default decoders/encoders
+	routes := map[string]map[string][]versionedHandler{}
+	for _, set := range sets {
+		opts := formatOpts(set.format)
+		opts = append(opts, svc.Options()...)
 
-	// register all JSON endpoints with our wrappers & default decoders/encoders
-	for path, epMethods := range jseps {
-		for method, ep := range epMethods {
-			if path == warmupURI && method == http.MethodGet {
-				warmupExists = true
-			}
-			// just pass the http.Request in if no decoder provided
-			if ep.Decoder == nil {
-				ep.Decoder = func(_ context.Context, r *http.Request) (interface{}, error) {
-					return r, nil
+		for path, epMethods := range set.endpoints {
+			for method, ep := range epMethods {
+				if reason := checkRoute(method, path, ep); reason != "" {
+					rerr.add(method, path, reason)
+					continue
 				}
+				if method == http.MethodGet {
+					warmupExists = warmupExists || path == warmupURI
+					startExists = startExists || path == startURI
+					stopExists = stopExists || path == stopURI
+					healthExists = healthExists || path == s.healthPath
+					readyExists = readyExists || path == s.readyPath
+					openAPIExists = openAPIExists || path == openAPIURI
+					routesExists = routesExists || path == routesURI
+				}
+				// just pass the http.Request in if no decoder provided
+				if ep.Decoder == nil {
+					ep.Decoder = func(_ context.Context, r *http.Request) (interface{}, error) {
+						return r, nil
+					}
+				}
+				// default to the helper for the set's format
+				if ep.Encoder == nil {
+					ep.Encoder = formatEncoder(set.format)
+				}
+				if routes[path] == nil {
+					routes[path] = map[string][]versionedHandler{}
+				}
+				if hasVersion(routes[path][method], set.version) {
+					rerr.add(method, path, "registered more than once")
+					continue
+				}
+				routes[path][method] = append(routes[path][method], versionedHandler{
+					version: set.version,
+					handler: ep.handler(svc, append(opts, ep.Options...)),
+					route: Route{
+						Method:   method,
+						Path:     path,
+						Format:   set.format,
+						Protocol: "http",
+						Version:  set.version,
+					},
+				})
 			}
-			// default to the httptransport helper
-			if ep.Encoder == nil {
-				ep.Encoder = httptransport.EncodeJSONResponse
-			}
-			s.mux.Handle(method, path,
-				httptransport.NewServer(
-					svc.Middleware(ep.Endpoint),
-					ep.Decoder,
-					ep.Encoder,
-					append(opts, ep.Options...)...))
 		}
 	}
-
-	// register all Protobuf endpoints with our wrappers & default decoders/encoders
-	for path, epMethods := range peps {
-		for method, ep := range epMethods {
-			if path == warmupURI && method == http.MethodGet {
-				warmupExists = true
-			}
-			// just pass the http.Request in if no decoder provided
-			if ep.Decoder == nil {
-				ep.Decoder = func(_ context.Context, r *http.Request) (interface{}, error) {
-					return r, nil
+	paths := make([]string, 0, len(routes))
+	for path := range routes {
+		paths = append(paths, path)
+	}
+	sortRoutes(paths)
+	for i, a := range paths {
+		for _, b := range paths[i+1:] {
+			for method, hs := range routes[a] {
+				if !sharesVersion(hs, routes[b][method]) || !ambiguousRoutes(a, b) {
+					continue
 				}
+				rerr.add(method, a, "ambiguous with "+b)
 			}
-			// default to the a protobuf helper
-			if ep.Encoder == nil {
-				ep.Encoder = EncodeProtoResponse
+		}
+	}
+	if isTwirp {
+		twirp.check("/twirp/", &rerr)
+	}
+	if isGRPCWeb {
+		grpcWeb.check("/", &rerr)
+	}
+	if len(rerr.Routes) > 0 {
+		rerr.sort()
+		return &rerr
+	}
+	for _, path := range paths {
+		methods := make([]string, 0, len(routes[path]))
+		for method := range routes[path] {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		for _, method := range methods {
+			s.handle(method, path, versionHandler(routes[path][method]))
+			for _, h := range routes[path][method] {
+				s.routes.add(h.route)
 			}
-			s.mux.Handle(method, path,
-				httptransport.NewServer(
-					svc.Middleware(ep.Endpoint),
-					ep.Decoder,
-					ep.Encoder,
-					append(opts, ep.Options...)...))
 		}
 	}
 
+	// expose the RPC methods via Twirp and gRPC-Web
+	if isTwirp {
+		s.registerTwirp(svc, twirp)
+	}
+	if isGRPCWeb {
+		s.registerGRPCWeb(svc, grpcWeb)
+	}
+
 	// add a warmup hook if one doesn't already exist
 	if !warmupExists {
-		s.mux.HandleFunc("GET", warmupURI,
-			func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+		var warm http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		if wr, ok := svc.(Warmer); ok {
+			warm = warmupHandler(wr.WarmupFuncs(), s.warmupTimeout)
+		}
+		s.handleImplicit("GET", warmupURI, "", warm)
+	}
+
+	// add the lifecycle hooks for manual and basic scaling instances
+	if st, ok := svc.(Starter); ok && s.lifecycle && !startExists {
+		s.handleImplicit("GET", startURI, "", startHandler(st))
+	}
+	if s.lifecycle && !stopExists {
+		// even without a Stopper, the OnShutdown hooks run on stop
+		st, _ := svc.(Stopper)
+		s.handleImplicit("GET", stopURI, "", s.stopHandler(st))
+	}
+
+	// serve the liveness and readiness probes
+	if hc, ok := svc.(HealthChecker); ok {
+		checks := hc.HealthChecks()
+		if s.healthPath != "" && !healthExists {
+			s.handleImplicit("GET", s.healthPath, FormatJSON, newHealthHandler(checks, false, s.healthCacheTTL))
+		}
+		if s.readyPath != "" && !readyExists {
+			s.handleImplicit("GET", s.readyPath, FormatJSON, newHealthHandler(checks, true, s.healthCacheTTL))
+		}
+	}
+
+	// serve the OpenAPI document if the service asked for it
+	if doc, ok := svc.(OpenAPIDocumenter); ok && !openAPIExists {
+		s.handleImplicit("GET", openAPIURI, FormatJSON, OpenAPIHandler(svc, doc.OpenAPIInfo()))
+	}
+
+	// serve the routes if the server was asked to
+	if s.exposeRoutes && !routesExists {
+		s.handleImplicit("GET", routesURI, FormatJSON, s.routesHandler())
+	}
+	if len(rerr.Routes) > 0 {
+		rerr.sort()
+		return &rerr
 	}
 	return nil
 }
 
+// formatOpts returns the default server options for endpoints of the given
+// format. Negotiated endpoints will respond in the format the caller asked for.
+func formatOpts(f Format) []httptransport.ServerOption {
+	opts := append([]httptransport.ServerOption{}, defaultOpts...)
+	if f == FormatNegotiated {
+		return append(opts,
+			httptransport.ServerBefore(func(ctx context.Context, r *http.Request) context.Context {
+				return context.WithValue(ctx, ContextKeyFormat, ResponseFormat(r))
+			}))
+	}
+	return append(opts, httptransport.ServerBefore(
+		func(ctx context.Context, r *http.Request) context.Context {
+			return context.WithValue(ctx, ContextKeyFormat, f)
+		}))
+}
+
+// formatEncoder returns the default response encoder for the given format.
+func formatEncoder(f Format) httptransport.EncodeResponseFunc {
+	switch f {
+	case FormatProto:
+		return EncodeProtoResponse
+	case FormatNegotiated:
+		return EncodeResponse
+	default:
+		return EncodeJSONResponse
+	}
+}
+
 const warmupURI = "/_ah/warmup"
 
 // EncodeProtoResponse is an httptransport.EncodeResponseFunc that serializes the response
@@ -177,12 +419,13 @@ func EncodeProtoResponse(ctx context.Context, w http.ResponseWriter, pres interf
 	if !ok {
 		return errors.New("response does not implement proto.Message")
 	}
-	w.Header().Set("Content-Type", "application/x-protobuf")
-	if headerer, ok := w.(httptransport.Headerer); ok {
+	if headerer, ok := pres.(httptransport.Headerer); ok {
 		for k := range headerer.Headers() {
 			w.Header().Set(k, headerer.Headers().Get(k))
 		}
 	}
+	// the body is always Protobuf, regardless of the response's headers
+	w.Header().Set("Content-Type", "application/x-protobuf")
 	code := http.StatusOK
 	if sc, ok := pres.(httptransport.StatusCoder); ok {
 		code = sc.StatusCode()
@@ -14,6 +14,37 @@ type HTTPEndpoint struct {
 	Decoder  httptransport.DecodeRequestFunc
 	Encoder  httptransport.EncodeResponseFunc
 	Options  []httptransport.ServerOption
+
+	// Describer is optional and used to document the endpoint
+	// in the service's OpenAPI document.
+	Describer Describer
+
+	// Middleware wraps the Endpoint in order, so the first middleware is the
+	// outermost. They run inside the service's Middleware.
+	Middleware []endpoint.Middleware
+	// HTTPMiddleware wraps the endpoint's http.Handler in order, so the first
+	// middleware is the outermost. They run inside the service's HTTPMiddleware.
+	HTTPMiddleware []func(http.Handler) http.Handler
+	// SkipServiceMiddleware will keep the service's Middleware from wrapping
+	// the endpoint, like for a public endpoint in an authenticated service.
+	SkipServiceMiddleware bool
+}
+
+// handler will build the http.Handler for the endpoint with all of its
+// middlewares applied.
+func (e HTTPEndpoint) handler(svc Service, opts []httptransport.ServerOption) http.Handler {
+	ep := e.Endpoint
+	for i := len(e.Middleware) - 1; i >= 0; i-- {
+		ep = e.Middleware[i](ep)
+	}
+	if !e.SkipServiceMiddleware {
+		ep = svc.Middleware(ep)
+	}
+	var h http.Handler = httptransport.NewServer(ep, e.Decoder, e.Encoder, opts...)
+	for i := len(e.HTTPMiddleware) - 1; i >= 0; i-- {
+		h = e.HTTPMiddleware[i](h)
+	}
+	return h
 }
 
 // Service is the most basic interface of a service that can be received and
@@ -58,6 +89,14 @@ type MixedService interface {
 	ProtoEndpointer
 }
 
+// NegotiatedService endpoints are for HTTP endpoints that serve both JSON and
+// Protobuf from a single endpoint definition.
+// This service will add default encoders if none provided.
+type NegotiatedService interface {
+	Service
+	NegotiatedEndpointer
+}
+
 // JSONEndpointer is for HTTP endpoints with JSON serialization
 // The first map's string is for the HTTP path, the second is for the http method.
 // For example:
@@ -111,3 +150,29 @@ type JSONEndpointer interface {
 type ProtoEndpointer interface {
 	ProtoEndpoints() map[string]map[string]HTTPEndpoint
 }
+
+// NegotiatedEndpointer is for HTTP endpoints that can serve JSON or Protobuf.
+// The request body format is selected by the 'Content-Type' header (see
+// NegotiatedDecoder) while responses and errors are serialized in the format selected
+// by a '.json' or '.proto' path suffix or the 'Accept' header.
+// The first map's string is for the HTTP path, the second is for the http method.
+// For example:
+//
+//	return map[string]map[string]marvin.HTTPEndpoint{
+//		"/cat/{id}": {
+//			"GET": {
+//				Endpoint: s.GetCatByID,
+//				Decoder:  decodeGetCatRequest,
+//			},
+//		},
+//		"/cats": {
+//			"PUT": {
+//				Endpoint: s.PutCats,
+//				Decoder:  marvin.NegotiatedDecoder(decodePutCatsRequest, decodePutCatsProtoRequest),
+//			},
+//		},
+//	}
+//
+type NegotiatedEndpointer interface {
+	NegotiatedEndpoints() map[string]map[string]HTTPEndpoint
+}
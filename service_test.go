@@ -0,0 +1,136 @@
+package marvin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+)
+
+// testService is a Service with configurable endpoints for the tests in this
+// package.
+type testService struct {
+	router    []RouterOption
+	endpoints map[string]map[string]HTTPEndpoint
+}
+
+func (s testService) HTTPMiddleware(h http.Handler) http.Handler { return h }
+
+func (s testService) Middleware(ep endpoint.Endpoint) endpoint.Endpoint { return ep }
+
+func (s testService) Options() []httptransport.ServerOption { return nil }
+
+func (s testService) RouterOptions() []RouterOption { return s.router }
+
+func (s testService) JSONEndpoints() map[string]map[string]HTTPEndpoint { return s.endpoints }
+
+// testEndpoint responds with the given value.
+func testEndpoint(res interface{}) HTTPEndpoint {
+	return HTTPEndpoint{
+		Endpoint: func(context.Context, interface{}) (interface{}, error) {
+			return res, nil
+		},
+	}
+}
+
+// newTestServer creates a Server that does not need the App Engine API.
+func newTestServer(t *testing.T, svc Service, opts ...ServerOption) Server {
+	t.Helper()
+	opts = append([]ServerOption{BaseContext(StandardContext)}, opts...)
+	svr, err := NewServerE(svc, opts...)
+	if err != nil {
+		t.Fatalf("unable to create server: %s", err)
+	}
+	return svr
+}
+
+// serve sends a request to the Server and returns the response.
+func serve(svr http.Handler, method, path, body string, hdrs ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	for i := 0; i+1 < len(hdrs); i += 2 {
+		r.Header.Set(hdrs[i], hdrs[i+1])
+	}
+	w := httptest.NewRecorder()
+	svr.ServeHTTP(w, r)
+	return w
+}
+
+// groupService is a testService that also serves Groups.
+type groupService struct {
+	testService
+	groups []Group
+}
+
+func (s groupService) Groups() []Group { return s.groups }
+
+// tagService tags every response with "service" in its Middleware.
+type tagService struct {
+	testService
+}
+
+func (s tagService) Middleware(ep endpoint.Endpoint) endpoint.Endpoint {
+	return tagMiddleware("service")(ep)
+}
+
+// tagMiddleware appends the tag to the string response of the endpoint.
+func tagMiddleware(tag string) endpoint.Middleware {
+	return func(ep endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			res, err := ep(ctx, req)
+			return res.(string) + " " + tag, err
+		}
+	}
+}
+
+// tagHeader adds the tag to the 'X-Tags' response header.
+func tagHeader(tag string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Tags", tag)
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestEndpointMiddleware(t *testing.T) {
+	tagged := testEndpoint("cat")
+	tagged.Middleware = []endpoint.Middleware{tagMiddleware("outer"), tagMiddleware("inner")}
+	tagged.HTTPMiddleware = []func(http.Handler) http.Handler{tagHeader("outer"), tagHeader("inner")}
+	skipped := tagged
+	skipped.SkipServiceMiddleware = true
+
+	svr := newTestServer(t, tagService{testService{endpoints: map[string]map[string]HTTPEndpoint{
+		"/plain":   {"GET": testEndpoint("cat")},
+		"/tagged":  {"GET": tagged},
+		"/skipped": {"GET": skipped},
+	}}})
+
+	tests := []struct {
+		path string
+
+		wantBody string
+		wantTags []string
+	}{
+		{path: "/plain", wantBody: `"cat service"`},
+		{path: "/tagged", wantBody: `"cat inner outer service"`, wantTags: []string{"outer", "inner"}},
+		{path: "/skipped", wantBody: `"cat inner outer"`, wantTags: []string{"outer", "inner"}},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			w := serve(svr, http.MethodGet, test.path, "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body)
+			}
+			if got := strings.TrimSpace(w.Body.String()); got != test.wantBody {
+				t.Errorf("expected body %s, got %s", test.wantBody, got)
+			}
+			if got := w.Header()["X-Tags"]; strings.Join(got, ",") != strings.Join(test.wantTags, ",") {
+				t.Errorf("expected tags %q, got %q", test.wantTags, got)
+			}
+		})
+	}
+}
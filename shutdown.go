@@ -0,0 +1,142 @@
+package marvin
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// OnShutdown is a server option to add a hook that is run by Server.Shutdown once
+// all in-flight requests have completed or the shutdown context is done, like
+// flushing buffered logs or metrics. Hooks run in the order they were added.
+func OnShutdown(hook func(ctx context.Context) error) ServerOption {
+	return func(s *Server) {
+		s.shutdown.hooks = append(s.shutdown.hooks, hook)
+	}
+}
+
+// shutdownState tracks the in-flight requests of a Server so it can be shut down
+// gracefully.
+type shutdownState struct {
+	hooks []func(ctx context.Context) error
+
+	// set to 1 once the Server has been asked to stop
+	stopping int32
+
+	mu      sync.Mutex
+	active  int
+	closing bool
+	idle    chan struct{}
+
+	once    sync.Once
+	hookErr error
+}
+
+func newShutdownState() *shutdownState {
+	return &shutdownState{idle: make(chan struct{})}
+}
+
+// stop will flag the Server as stopping.
+func (st *shutdownState) stop() {
+	atomic.StoreInt32(&st.stopping, 1)
+}
+
+// stopped returns true once the Server has been asked to stop.
+func (st *shutdownState) stopped() bool {
+	return st != nil && atomic.LoadInt32(&st.stopping) == 1
+}
+
+// begin will count a new request. It returns false if the Server is shutting down.
+func (st *shutdownState) begin() bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.closing {
+		return false
+	}
+	st.active++
+	return true
+}
+
+// end will count a completed request.
+func (st *shutdownState) end() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.active--
+	if st.closing && st.active == 0 {
+		close(st.idle)
+	}
+}
+
+// close will stop new requests from beginning and return a channel that is
+// closed once there are no more in-flight requests.
+func (st *shutdownState) close() <-chan struct{} {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if !st.closing {
+		st.closing = true
+		if st.active == 0 {
+			close(st.idle)
+		}
+	}
+	return st.idle
+}
+
+// track will count the requests served by the handler and respond to any that
+// arrive after the Server began shutting down with a 503.
+func (s Server) track(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.shutdown.begin() {
+			w.Header().Set("Connection", "close")
+			http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		defer s.shutdown.end()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Shutdown will gracefully shut the Server down. It flags the instance as
+// Stopping, responds to new requests with a 503, waits for in-flight requests to
+// complete and then runs the OnShutdown hooks. If the context is done before the
+// requests complete, the hooks are still run and the context's error is returned.
+// Otherwise, the first error returned by a hook is returned. The hooks get a
+// context with the values of the given one and DefaultShutdownTimeout of their
+// own, since waiting for the requests may have used up its deadline.
+//
+// Servers started with Init are shut down when App Engine sends a stop request to
+// a manual or basic scaling instance and Servers started with Run are shut down
+// on SIGTERM. Routes marvin adds on behalf of the service, like /_ah/stop, are not
+// tracked or refused.
+func (s Server) Shutdown(ctx context.Context) error {
+	if s.shutdown == nil {
+		return nil
+	}
+	s.shutdown.stop()
+
+	var err error
+	idle := s.shutdown.close()
+	select {
+	case <-idle:
+	default:
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	s.shutdown.once.Do(func() {
+		hctx, cancel := context.WithTimeout(detachedContext{ctx}, DefaultShutdownTimeout)
+		defer cancel()
+		for _, hook := range s.shutdown.hooks {
+			if herr := hook(hctx); herr != nil && s.shutdown.hookErr == nil {
+				s.shutdown.hookErr = herr
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return s.shutdown.hookErr
+}
@@ -0,0 +1,98 @@
+package marvin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type healthService struct {
+	testService
+}
+
+func (healthService) HealthChecks() []HealthCheck {
+	return []HealthCheck{{Name: "ok", Check: func(context.Context) error { return nil }}}
+}
+
+func TestShutdown(t *testing.T) {
+	hookErr := errors.New("unable to flush")
+	tests := []struct {
+		name     string
+		inFlight bool
+		timeout  time.Duration
+		hook     error
+
+		wantErr error
+	}{
+		{name: "idle", timeout: time.Second},
+		{name: "hook error", timeout: time.Second, hook: hookErr, wantErr: hookErr},
+		{name: "in-flight request", inFlight: true, timeout: 10 * time.Millisecond, wantErr: context.DeadlineExceeded},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var hooks int
+			release, started := make(chan struct{}), make(chan struct{})
+			svc := healthService{testService{endpoints: map[string]map[string]HTTPEndpoint{
+				"/stopping": {"GET": {
+					Endpoint: func(ctx context.Context, _ interface{}) (interface{}, error) {
+						return Stopping(ctx), nil
+					},
+				}},
+				"/slow": {"GET": {
+					Endpoint: func(context.Context, interface{}) (interface{}, error) {
+						close(started)
+						<-release
+						return "done", nil
+					},
+				}},
+			}}}
+			svr := newTestServer(t, svc, OnShutdown(func(ctx context.Context) error {
+				hooks++
+				if _, ok := ctx.Deadline(); !ok || ctx.Err() != nil {
+					t.Errorf("expected the hooks to get a fresh deadline, got %v", ctx.Err())
+				}
+				return test.hook
+			}))
+			other := newTestServer(t, svc)
+			defer close(release)
+
+			if test.inFlight {
+				go serve(svr, http.MethodGet, "/slow", "")
+				<-started
+			}
+			if w := serve(svr, http.MethodGet, "/stopping", ""); w.Body.String() != "false" {
+				t.Fatalf("expected the server not to be stopping, got %s", w.Body)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), test.timeout)
+			defer cancel()
+			if err := svr.Shutdown(ctx); err != test.wantErr {
+				t.Errorf("expected error %v, got %v", test.wantErr, err)
+			}
+			if err := svr.Shutdown(ctx); err != test.wantErr {
+				t.Errorf("expected the second shutdown to return %v, got %v", test.wantErr, err)
+			}
+			if hooks != 1 {
+				t.Errorf("expected the hooks to run once, ran %d times", hooks)
+			}
+
+			if !svr.Stopping() || other.Stopping() {
+				t.Errorf("expected only the shut down server to be stopping")
+			}
+			if w := serve(svr, http.MethodGet, "/stopping", ""); w.Code != http.StatusServiceUnavailable {
+				t.Errorf("expected new requests to get a 503, got %d", w.Code)
+			}
+			if w := serve(svr, http.MethodGet, DefaultReadyPath, ""); w.Code != http.StatusServiceUnavailable {
+				t.Errorf("expected the ready path to get a 503, got %d", w.Code)
+			}
+			if w := serve(other, http.MethodGet, "/stopping", ""); w.Body.String() != "false" {
+				t.Errorf("expected the other server not to be stopping, got %d %s", w.Code, w.Body)
+			}
+			if w := serve(other, http.MethodGet, DefaultReadyPath, ""); w.Code != http.StatusOK {
+				t.Errorf("expected the other server to be ready, got %d", w.Code)
+			}
+		})
+	}
+}
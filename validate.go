@@ -0,0 +1,826 @@
+package marvin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// ValidationError describes a single way a request or response broke the rules
+// declared in an OpenAPI specification.
+type ValidationError struct {
+	// In is one of "path", "query", "header", "body" or "response".
+	In string `json:"in"`
+	// Name is the parameter name or the location of the problem within the
+	// body, like "request.link.url".
+	Name   string `json:"name,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// ValidationErrors is the list of problems found with a request or response.
+type ValidationErrors []ValidationError
+
+// Error is to implement error
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, e := range v {
+		msgs[i] = e.In
+		if e.Name != "" {
+			msgs[i] += " " + e.Name
+		}
+		msgs[i] += ": " + e.Reason
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ValidatorOption sets optional SpecValidator behavior.
+type ValidatorOption func(*SpecValidator)
+
+// StrictResponses will make the SpecValidator check responses as well as requests.
+// Any response with an undeclared status code or a JSON body that does not match
+// its schema will be replaced with a 500 describing the mismatch, unless the
+// handler has already flushed it. This is meant for test suites and should not
+// be used in production.
+func StrictResponses() ValidatorOption {
+	return func(v *SpecValidator) {
+		v.checkResponses = true
+		v.strict = true
+	}
+}
+
+// OnResponseMismatch will make the SpecValidator check responses as well as
+// requests. The given function will be called for every response that does
+// not match the specification and the original response will still be sent.
+func OnResponseMismatch(f func(r *http.Request, err error)) ValidatorOption {
+	return func(v *SpecValidator) {
+		v.checkResponses = true
+		v.onMismatch = f
+	}
+}
+
+// SpecValidator validates requests, and optionally responses, against an OpenAPI
+// 2.0 (Swagger) or 3.0 specification like the reading-list example's service.yaml.
+//
+// Path, query and header parameters are checked for presence, type, pattern, enum
+// and range. JSON bodies are checked against their schemas. Protobuf bodies cannot
+// be described by the specification and are passed through untouched.
+type SpecValidator struct {
+	doc    map[string]interface{}
+	routes []*specRoute
+	// compiled schema patterns keyed by their expression
+	patterns map[string]*regexp.Regexp
+
+	checkResponses bool
+	strict         bool
+	onMismatch     func(*http.Request, error)
+}
+
+type specRoute struct {
+	re    *regexp.Regexp
+	names []string
+	vars  int
+	ops   map[string]*specOp
+}
+
+type specOp struct {
+	params       []map[string]interface{}
+	bodyRequired bool
+	// body and response schemas keyed by media type
+	body      map[string]map[string]interface{}
+	responses map[string]map[string]map[string]interface{}
+}
+
+// NewSpecValidator will parse the given JSON or YAML OpenAPI specification and
+// return a validator that can be used as a Service.HTTPMiddleware:
+//
+//	func (s service) HTTPMiddleware(h http.Handler) http.Handler {
+//		return s.validator.Middleware(h)
+//	}
+func NewSpecValidator(spec []byte, opts ...ValidatorOption) (*SpecValidator, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(spec, &raw); err != nil {
+		return nil, errors.Wrap(err, "unable to parse specification")
+	}
+	doc, ok := yamlToJSON(raw).(map[string]interface{})
+	if !ok {
+		return nil, errors.New("specification is not an object")
+	}
+	v := &SpecValidator{doc: doc}
+	for _, opt := range opts {
+		opt(v)
+	}
+	if err := v.parseRoutes(); err != nil {
+		return nil, err
+	}
+	v.patterns = map[string]*regexp.Regexp{}
+	if err := v.compilePatterns(doc, false); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// compilePatterns will compile every schema pattern in the document so they are
+// not compiled for each request. When named is true raw is a map of schemas by
+// name, like properties or definitions, so its keys are not schema keywords.
+func (v *SpecValidator) compilePatterns(raw interface{}, named bool) error {
+	switch t := raw.(type) {
+	case map[string]interface{}:
+		for key, val := range t {
+			if named {
+				if err := v.compilePatterns(val, false); err != nil {
+					return err
+				}
+				continue
+			}
+			switch key {
+			case "example", "examples", "default", "enum":
+				// values, not schemas
+				continue
+			case "properties", "definitions", "schemas":
+				if err := v.compilePatterns(val, true); err != nil {
+					return err
+				}
+				continue
+			case "pattern":
+				if p, ok := val.(string); ok {
+					if _, seen := v.patterns[p]; seen {
+						continue
+					}
+					re, err := regexp.Compile(p)
+					if err != nil {
+						return errors.Wrapf(err, "invalid specification pattern %q", p)
+					}
+					v.patterns[p] = re
+					continue
+				}
+			}
+			if err := v.compilePatterns(val, false); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, val := range t {
+			if err := v.compilePatterns(val, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// yamlToJSON will convert the map[interface{}]interface{} values the YAML parser
+// produces into values encoding/json would produce.
+func yamlToJSON(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[fmt.Sprint(k)] = yamlToJSON(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range t {
+			t[i] = yamlToJSON(val)
+		}
+		return t
+	case int:
+		return json.Number(strconv.Itoa(t))
+	case int64:
+		return json.Number(strconv.FormatInt(t, 10))
+	case uint64:
+		return json.Number(strconv.FormatUint(t, 10))
+	case float64:
+		return json.Number(strconv.FormatFloat(t, 'g', -1, 64))
+	default:
+		return v
+	}
+}
+
+var specMethods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
+func (v *SpecValidator) parseRoutes() error {
+	prefix, _ := v.doc["basePath"].(string)
+	if servers, ok := v.doc["servers"].([]interface{}); ok && len(servers) > 0 {
+		if s, ok := servers[0].(map[string]interface{}); ok {
+			if u, err := url.Parse(fmt.Sprint(s["url"])); err == nil {
+				prefix = u.Path
+			}
+		}
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	paths, _ := v.doc["paths"].(map[string]interface{})
+	for path, item := range paths {
+		pathItem, _ := v.resolve(item).(map[string]interface{})
+		if pathItem == nil {
+			continue
+		}
+		parts, err := parsePath(path)
+		if err != nil {
+			return errors.Wrap(err, "invalid specification path")
+		}
+		route := &specRoute{ops: map[string]*specOp{}}
+		expr := "^" + regexp.QuoteMeta(prefix)
+		for _, p := range parts {
+			if !p.isVar() {
+				expr += regexp.QuoteMeta(p.literal)
+				continue
+			}
+			expr += "([^/]+)"
+			route.names = append(route.names, p.name)
+			route.vars++
+		}
+		route.re, err = regexp.Compile(expr + "$")
+		if err != nil {
+			return errors.Wrapf(err, "invalid specification path %q", path)
+		}
+
+		shared := v.list(pathItem["parameters"])
+		for _, method := range specMethods {
+			rawOp, ok := pathItem[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			route.ops[strings.ToUpper(method)] = v.parseOp(rawOp, shared)
+		}
+		v.routes = append(v.routes, route)
+	}
+	// prefer the most specific paths when more than one matches
+	sort.Slice(v.routes, func(i, j int) bool {
+		if v.routes[i].vars != v.routes[j].vars {
+			return v.routes[i].vars < v.routes[j].vars
+		}
+		return len(v.routes[i].re.String()) > len(v.routes[j].re.String())
+	})
+	return nil
+}
+
+func (v *SpecValidator) parseOp(raw map[string]interface{}, shared []interface{}) *specOp {
+	op := &specOp{
+		body:      map[string]map[string]interface{}{},
+		responses: map[string]map[string]map[string]interface{}{},
+	}
+	consumes := v.mediaTypes(raw, "consumes")
+	produces := v.mediaTypes(raw, "produces")
+
+	// operation level parameters override path level ones
+	seen := map[string]bool{}
+	params := append([]interface{}{}, v.list(raw["parameters"])...)
+	for _, rp := range append(params, shared...) {
+		p, _ := v.resolve(rp).(map[string]interface{})
+		if p == nil {
+			continue
+		}
+		key := fmt.Sprint(p["in"]) + ":" + fmt.Sprint(p["name"])
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if p["in"] == "body" {
+			op.bodyRequired = p["required"] == true
+			schema, _ := p["schema"].(map[string]interface{})
+			for _, mt := range consumes {
+				op.body[mt] = schema
+			}
+			continue
+		}
+		op.params = append(op.params, p)
+	}
+
+	if rb, ok := v.resolve(raw["requestBody"]).(map[string]interface{}); ok {
+		op.bodyRequired = rb["required"] == true
+		op.body = v.content(rb["content"])
+	}
+
+	responses, _ := raw["responses"].(map[string]interface{})
+	for code, rr := range responses {
+		res, _ := v.resolve(rr).(map[string]interface{})
+		if res == nil {
+			continue
+		}
+		if schema, ok := res["schema"].(map[string]interface{}); ok {
+			op.responses[code] = map[string]map[string]interface{}{}
+			for _, mt := range produces {
+				op.responses[code][mt] = schema
+			}
+			continue
+		}
+		op.responses[code] = v.content(res["content"])
+	}
+	return op
+}
+
+// mediaTypes returns the Swagger 2.0 consumes or produces list for an operation,
+// falling back to the document's list and then JSON.
+func (v *SpecValidator) mediaTypes(op map[string]interface{}, key string) []string {
+	list, ok := op[key].([]interface{})
+	if !ok {
+		list, _ = v.doc[key].([]interface{})
+	}
+	var out []string
+	for _, mt := range list {
+		out = append(out, fmt.Sprint(mt))
+	}
+	if len(out) == 0 {
+		out = []string{"application/json"}
+	}
+	return out
+}
+
+// content parses an OpenAPI 3.0 content map into schemas keyed by media type.
+func (v *SpecValidator) content(raw interface{}) map[string]map[string]interface{} {
+	out := map[string]map[string]interface{}{}
+	content, _ := raw.(map[string]interface{})
+	for mt, c := range content {
+		cm, _ := c.(map[string]interface{})
+		schema, _ := cm["schema"].(map[string]interface{})
+		out[mt] = schema
+	}
+	return out
+}
+
+func (v *SpecValidator) list(raw interface{}) []interface{} {
+	l, _ := raw.([]interface{})
+	return l
+}
+
+// resolve will follow any local JSON reference in the given value.
+func (v *SpecValidator) resolve(raw interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			return raw
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return raw
+		}
+		raw = v.lookup(ref)
+	}
+	return nil
+}
+
+func (v *SpecValidator) lookup(ref string) interface{} {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil
+	}
+	var cur interface{} = v.doc
+	for _, tok := range strings.Split(ref[2:], "/") {
+		tok = strings.Replace(strings.Replace(tok, "~1", "/", -1), "~0", "~", -1)
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = m[tok]
+	}
+	return cur
+}
+
+// match will find the specification operation for the given request.
+func (v *SpecValidator) match(r *http.Request) (*specOp, map[string]string) {
+	for _, route := range v.routes {
+		m := route.re.FindStringSubmatch(r.URL.Path)
+		if m == nil {
+			continue
+		}
+		op, ok := route.ops[r.Method]
+		if !ok {
+			continue
+		}
+		vars := map[string]string{}
+		for i, name := range route.names {
+			vars[name] = m[i+1]
+		}
+		return op, vars
+	}
+	return nil, nil
+}
+
+// Middleware will validate requests before passing them on to the given
+// http.Handler. Requests that do not match the specification receive a 400
+// with a JSON body listing the problems found, as do requests whose body cannot
+// be read, and JSON bodies larger than DefaultMaxBodyBytes receive a 413.
+// Requests for paths the specification does not describe are passed through
+// untouched.
+func (v *SpecValidator) Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op, vars := v.match(r)
+		if op == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if errs := v.validateRequest(op, vars, r); len(errs) > 0 {
+			code := http.StatusBadRequest
+			if errs[len(errs)-1].Reason == reasonTooLarge {
+				code = http.StatusRequestEntityTooLarge
+			}
+			writeValidationErrors(w, code, "request does not match specification", errs)
+			return
+		}
+		if !v.checkResponses {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		bw := &bufferedWriter{ResponseWriter: w, code: http.StatusOK}
+		h.ServeHTTP(bw, r)
+		if errs := v.validateResponse(op, bw); len(errs) > 0 {
+			if v.onMismatch != nil {
+				v.onMismatch(r, errs)
+			}
+			if v.strict && !bw.flushed {
+				w.Header().Del("Content-Length")
+				writeValidationErrors(w, http.StatusInternalServerError,
+					"response does not match specification", errs)
+				return
+			}
+		}
+		if !bw.flushed {
+			bw.flush()
+		}
+	})
+}
+
+func writeValidationErrors(w http.ResponseWriter, code int, msg string, errs ValidationErrors) {
+	b, _ := json.Marshal(struct {
+		Message string           `json:"message"`
+		Errors  ValidationErrors `json:"errors"`
+	}{msg, errs})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	w.Write(b)
+}
+
+// bufferedWriter holds on to a response so it can be checked before it is sent.
+// Once the handler flushes, the response is sent as it is written and can no
+// longer be replaced.
+type bufferedWriter struct {
+	http.ResponseWriter
+	code    int
+	buf     bytes.Buffer
+	flushed bool
+}
+
+func (b *bufferedWriter) WriteHeader(code int) {
+	if !b.flushed {
+		b.code = code
+	}
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	if b.flushed {
+		b.buf.Write(p)
+		return b.ResponseWriter.Write(p)
+	}
+	return b.buf.Write(p)
+}
+
+// Flush will send the response written so far and pass the flush on to the
+// underlying http.ResponseWriter if it supports it.
+func (b *bufferedWriter) Flush() {
+	if !b.flushed {
+		b.flush()
+		b.flushed = true
+	}
+	if f, ok := b.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (b *bufferedWriter) flush() {
+	b.ResponseWriter.WriteHeader(b.code)
+	b.ResponseWriter.Write(b.buf.Bytes())
+}
+
+const (
+	// reasonTooLarge is the reason given for request bodies larger than the
+	// DefaultMaxBodyBytes, which are not read any further.
+	reasonTooLarge = "is too large"
+	// reasonUnreadable is the reason given for request bodies that could not be
+	// read in full.
+	reasonUnreadable = "could not be read"
+)
+
+func (v *SpecValidator) validateRequest(op *specOp, vars map[string]string, r *http.Request) ValidationErrors {
+	var errs ValidationErrors
+	query := r.URL.Query()
+	for _, p := range op.params {
+		name, _ := p["name"].(string)
+		in, _ := p["in"].(string)
+		var (
+			vals  []string
+			found bool
+		)
+		switch in {
+		case "path":
+			var val string
+			val, found = vars[name]
+			vals = []string{val}
+		case "query":
+			vals, found = query[name]
+		case "header":
+			vals, found = r.Header[http.CanonicalHeaderKey(name)]
+		default:
+			continue
+		}
+		if !found || len(vals) == 0 {
+			if p["required"] == true {
+				errs = append(errs, ValidationError{In: in, Name: name, Reason: "is required"})
+			}
+			continue
+		}
+		// Swagger 2.0 keeps the schema on the parameter itself
+		schema, ok := p["schema"].(map[string]interface{})
+		if !ok {
+			schema = p
+		}
+		errs = append(errs, v.validateParam(in, name, schema, vals)...)
+	}
+
+	if len(op.body) == 0 {
+		return errs
+	}
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if RequestFormat(r) != FormatJSON {
+		return errs
+	}
+	schema := bodySchema(op.body, mt)
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(io.LimitReader(r.Body, DefaultMaxBodyBytes+1))
+		if err != nil {
+			return append(errs, ValidationError{In: "body", Reason: reasonUnreadable})
+		}
+		if len(body) > DefaultMaxBodyBytes {
+			return append(errs, ValidationError{In: "body", Reason: reasonTooLarge})
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.bodyRequired {
+			errs = append(errs, ValidationError{In: "body", Reason: "is required"})
+		}
+		return errs
+	}
+	val, err := decodeJSONValue(body)
+	if err != nil {
+		return append(errs, ValidationError{In: "body", Reason: "is not valid JSON"})
+	}
+	if schema != nil {
+		v.validateValue("body", "", schema, val, &errs)
+	}
+	return errs
+}
+
+func (v *SpecValidator) validateResponse(op *specOp, bw *bufferedWriter) ValidationErrors {
+	code := strconv.Itoa(bw.code)
+	content, ok := op.responses[code]
+	if !ok {
+		content, ok = op.responses[code[:1]+"XX"]
+	}
+	if !ok {
+		content, ok = op.responses["default"]
+	}
+	if !ok {
+		return ValidationErrors{{In: "response", Name: code, Reason: "status code is not declared"}}
+	}
+	mt, _, _ := mime.ParseMediaType(bw.Header().Get("Content-Type"))
+	if mediaTypeFormat(mt) != FormatJSON || bw.buf.Len() == 0 {
+		return nil
+	}
+	schema := bodySchema(content, mt)
+	if schema == nil {
+		return nil
+	}
+	val, err := decodeJSONValue(bw.buf.Bytes())
+	if err != nil {
+		return ValidationErrors{{In: "response", Reason: "is not valid JSON"}}
+	}
+	var errs ValidationErrors
+	v.validateValue("response", "", schema, val, &errs)
+	return errs
+}
+
+// bodySchema finds the schema for the given media type, falling back to any JSON
+// media type the specification declares.
+func bodySchema(content map[string]map[string]interface{}, mt string) map[string]interface{} {
+	if s, ok := content[mt]; ok {
+		return s
+	}
+	for cmt, s := range content {
+		if mediaTypeFormat(cmt) == FormatJSON {
+			return s
+		}
+	}
+	return nil
+}
+
+func decodeJSONValue(b []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var val interface{}
+	err := dec.Decode(&val)
+	return val, err
+}
+
+// validateParam will convert the string values of a parameter to their declared
+// types before validating them against the schema.
+func (v *SpecValidator) validateParam(in, name string, schema map[string]interface{}, vals []string) ValidationErrors {
+	var errs ValidationErrors
+	schema = v.schema(schema)
+	if schema["type"] == "array" {
+		items, _ := schema["items"].(map[string]interface{})
+		var list []interface{}
+		for _, val := range vals {
+			for _, item := range strings.Split(val, ",") {
+				list = append(list, paramValue(v.schema(items), item))
+			}
+		}
+		v.validateValue(in, name, schema, list, &errs)
+		return errs
+	}
+	v.validateValue(in, name, schema, paramValue(schema, vals[0]), &errs)
+	return errs
+}
+
+// paramValue converts a string parameter to the JSON type its schema declares.
+func paramValue(schema map[string]interface{}, val string) interface{} {
+	switch schema["type"] {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(val, 64); err == nil {
+			return json.Number(val)
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(val); err == nil {
+			return b
+		}
+	}
+	return val
+}
+
+func (v *SpecValidator) schema(s map[string]interface{}) map[string]interface{} {
+	out, _ := v.resolve(s).(map[string]interface{})
+	return out
+}
+
+// validateValue checks a decoded JSON value against the commonly used subset of
+// JSON Schema supported by OpenAPI.
+func (v *SpecValidator) validateValue(in, name string, schema map[string]interface{}, val interface{}, errs *ValidationErrors) {
+	schema = v.schema(schema)
+	if schema == nil {
+		return
+	}
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, ValidationError{In: in, Name: name, Reason: fmt.Sprintf(format, args...)})
+	}
+	for _, sub := range v.list(schema["allOf"]) {
+		s, _ := sub.(map[string]interface{})
+		v.validateValue(in, name, s, val, errs)
+	}
+
+	if val == nil {
+		if schema["nullable"] == true || schema["type"] == nil {
+			return
+		}
+		fail("must not be null")
+		return
+	}
+
+	if typ, ok := schema["type"].(string); ok && !jsonTypeMatches(typ, val) {
+		fail("must be of type %s", typ)
+		return
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && !inEnum(enum, val) {
+		fail("must be one of %v", enum)
+	}
+
+	switch t := val.(type) {
+	case string:
+		if p, ok := schema["pattern"].(string); ok {
+			if re := v.patterns[p]; re != nil && !re.MatchString(t) {
+				fail("must match pattern %s", p)
+			}
+		}
+		if n, ok := schemaNumber(schema, "minLength"); ok && float64(len([]rune(t))) < n {
+			fail("must be at least %v characters", n)
+		}
+		if n, ok := schemaNumber(schema, "maxLength"); ok && float64(len([]rune(t))) > n {
+			fail("must be at most %v characters", n)
+		}
+	case json.Number:
+		f, _ := t.Float64()
+		if n, ok := schemaNumber(schema, "minimum"); ok && (f < n || f == n && schema["exclusiveMinimum"] == true) {
+			fail("must be greater than %s%v", orEqual(schema["exclusiveMinimum"]), n)
+		}
+		if n, ok := schemaNumber(schema, "maximum"); ok && (f > n || f == n && schema["exclusiveMaximum"] == true) {
+			fail("must be less than %s%v", orEqual(schema["exclusiveMaximum"]), n)
+		}
+	case []interface{}:
+		if n, ok := schemaNumber(schema, "minItems"); ok && float64(len(t)) < n {
+			fail("must have at least %v items", n)
+		}
+		if n, ok := schemaNumber(schema, "maxItems"); ok && float64(len(t)) > n {
+			fail("must have at most %v items", n)
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range t {
+				v.validateValue(in, joinName(name, strconv.Itoa(i)), items, item, errs)
+			}
+		}
+	case map[string]interface{}:
+		for _, req := range v.list(schema["required"]) {
+			if _, ok := t[fmt.Sprint(req)]; !ok {
+				*errs = append(*errs, ValidationError{In: in, Name: joinName(name, fmt.Sprint(req)), Reason: "is required"})
+			}
+		}
+		props, _ := schema["properties"].(map[string]interface{})
+		for key, pval := range t {
+			if ps, ok := props[key].(map[string]interface{}); ok {
+				v.validateValue(in, joinName(name, key), ps, pval, errs)
+				continue
+			}
+			switch ap := schema["additionalProperties"].(type) {
+			case bool:
+				if !ap {
+					*errs = append(*errs, ValidationError{In: in, Name: joinName(name, key), Reason: "is not allowed"})
+				}
+			case map[string]interface{}:
+				v.validateValue(in, joinName(name, key), ap, pval, errs)
+			}
+		}
+	}
+}
+
+func jsonTypeMatches(typ string, val interface{}) bool {
+	switch typ {
+	case "string":
+		_, ok := val.(string)
+		return ok
+	case "boolean":
+		_, ok := val.(bool)
+		return ok
+	case "number":
+		_, ok := val.(json.Number)
+		return ok
+	case "integer":
+		n, ok := val.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := n.Float64()
+		return err == nil && f == math.Trunc(f)
+	case "array":
+		_, ok := val.([]interface{})
+		return ok
+	case "object":
+		_, ok := val.(map[string]interface{})
+		return ok
+	}
+	return true
+}
+
+func inEnum(enum []interface{}, val interface{}) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(val) {
+			return true
+		}
+	}
+	return false
+}
+
+func schemaNumber(schema map[string]interface{}, key string) (float64, bool) {
+	n, ok := schema[key].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	f, err := n.Float64()
+	return f, err == nil
+}
+
+func orEqual(exclusive interface{}) string {
+	if exclusive == true {
+		return ""
+	}
+	return "or equal to "
+}
+
+func joinName(parent, child string) string {
+	if parent == "" {
+		return child
+	}
+	return parent + "." + child
+}
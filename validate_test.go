@@ -0,0 +1,173 @@
+package marvin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testSpec = `
+swagger: '2.0'
+info:
+  title: Cats
+  version: "1.0"
+paths:
+  /cat/{id}:
+    put:
+      parameters:
+        - name: id
+          in: path
+          required: true
+          type: string
+          pattern: ^[0-9]+$
+        - name: cat
+          in: body
+          required: true
+          schema:
+            $ref: '#/definitions/Cat'
+      responses:
+        200:
+          description: ok
+definitions:
+  Cat:
+    type: object
+    required: [name]
+    properties:
+      name:
+        type: string
+        pattern: ^[A-Z]
+      pattern:
+        type: string
+        example: "[not a regexp"
+      default:
+        type: string
+        pattern: ^[0-9]+$
+`
+
+func TestSpecValidator(t *testing.T) {
+	v, err := NewSpecValidator([]byte(testSpec))
+	if err != nil {
+		t.Fatalf("unable to create validator: %s", err)
+	}
+	h := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name string
+		path string
+		body string
+
+		wantCode int
+	}{
+		{
+			name:     "valid",
+			path:     "/cat/1",
+			body:     `{"name":"Tom"}`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "bad path pattern",
+			path:     "/cat/tom",
+			body:     `{"name":"Tom"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "bad body pattern",
+			path:     "/cat/1",
+			body:     `{"name":"tom"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "bad pattern on property named like a keyword",
+			path:     "/cat/1",
+			body:     `{"name":"Tom","default":"tom"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "missing required",
+			path:     "/cat/1",
+			body:     `{}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "too large",
+			path:     "/cat/1",
+			body:     `{"name":"T` + strings.Repeat("o", DefaultMaxBodyBytes) + `m"}`,
+			wantCode: http.StatusRequestEntityTooLarge,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, test.path, strings.NewReader(test.body))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != test.wantCode {
+				t.Errorf("expected status %d, got %d: %s", test.wantCode, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestSpecValidatorUnreadableBody(t *testing.T) {
+	v, err := NewSpecValidator([]byte(testSpec))
+	if err != nil {
+		t.Fatalf("unable to create validator: %s", err)
+	}
+	h := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler should not be called")
+	}))
+	r := httptest.NewRequest(http.MethodPut, "/cat/1", errReader{})
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+}
+
+func TestSpecValidatorFlush(t *testing.T) {
+	v, err := NewSpecValidator([]byte(testSpec), StrictResponses())
+	if err != nil {
+		t.Fatalf("unable to create validator: %s", err)
+	}
+	h := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("expected the response writer to be an http.Flusher")
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("purr"))
+		f.Flush()
+		w.Write([]byte("purr"))
+	}))
+	r := httptest.NewRequest(http.MethodPut, "/cat/1", strings.NewReader(`{"name":"Tom"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if !w.Flushed {
+		t.Error("expected the response to be flushed")
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if got := w.Body.String(); got != "purrpurr" {
+		t.Errorf("expected body %q, got %q", "purrpurr", got)
+	}
+}
+
+func TestSpecValidatorInvalidPattern(t *testing.T) {
+	spec := strings.Replace(testSpec, "pattern: ^[A-Z]", "pattern: ^[A-Z", 1)
+	if _, err := NewSpecValidator([]byte(spec)); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+}
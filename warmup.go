@@ -0,0 +1,79 @@
+package marvin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultWarmupTimeout is the time the WarmupFuncs of a Warmer have to complete
+// unless the WarmupTimeout option is used.
+const DefaultWarmupTimeout = 30 * time.Second
+
+// WarmupFunc prepares a new instance of a service before it receives traffic,
+// like pre-filling caches, opening connections or loading config. It should
+// return once the context is done.
+type WarmupFunc func(ctx context.Context) error
+
+// Warmer can be implemented by a Service to run WarmupFuncs when App Engine sends
+// a warmup request to a new instance. All of the funcs run concurrently and the
+// warmup request responds with a 500 if any of them fail or a 504 if they do not
+// complete before the warmup timeout. Failures are logged with the App Engine
+// logger.
+//
+// If a service registers its own /_ah/warmup endpoint, the Warmer is ignored.
+// Warmup requests must be enabled with `inbound_services: [warmup]` in app.yaml.
+type Warmer interface {
+	WarmupFuncs() []WarmupFunc
+}
+
+// WarmupTimeout will set the time the WarmupFuncs of a Warmer have to complete.
+func WarmupTimeout(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.warmupTimeout = d
+	}
+}
+
+// warmupHandler returns the handler for /_ah/warmup that will run the given funcs.
+func warmupHandler(funcs []WarmupFunc, timeout time.Duration) http.Handler {
+	if timeout <= 0 {
+		timeout = DefaultWarmupTimeout
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		errs := make(chan error, len(funcs))
+		for i, f := range funcs {
+			go func(i int, f WarmupFunc) {
+				defer func() {
+					if p := recover(); p != nil {
+						errs <- fmt.Errorf("warmup func %d panicked: %v", i, p)
+					}
+				}()
+				if err := f(ctx); err != nil {
+					errs <- fmt.Errorf("warmup func %d failed: %s", i, err)
+					return
+				}
+				errs <- nil
+			}(i, f)
+		}
+
+		code := http.StatusOK
+		for range funcs {
+			select {
+			case err := <-errs:
+				if err != nil {
+					logErrorf(r.Context(), "%s", err)
+					code = http.StatusInternalServerError
+				}
+			case <-ctx.Done():
+				logErrorf(r.Context(), "warmup did not complete within %s", timeout)
+				w.WriteHeader(http.StatusGatewayTimeout)
+				return
+			}
+		}
+		w.WriteHeader(code)
+	})
+}
@@ -0,0 +1,56 @@
+package marvin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// warmService is a testService that implements Warmer.
+type warmService struct {
+	testService
+	funcs []WarmupFunc
+}
+
+func (s warmService) WarmupFuncs() []WarmupFunc { return s.funcs }
+
+func TestWarmup(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	fail := func(context.Context) error { return errors.New("cache is cold") }
+	hang := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	cat := map[string]map[string]HTTPEndpoint{"/cat": {"GET": testEndpoint("cat")}}
+
+	tests := []struct {
+		name string
+		svc  Service
+
+		wantCode int
+	}{
+		{name: "no warmer", svc: testService{endpoints: cat}, wantCode: http.StatusOK},
+		{name: "success", svc: warmService{testService{endpoints: cat}, []WarmupFunc{ok, ok}}, wantCode: http.StatusOK},
+		{name: "failure", svc: warmService{testService{endpoints: cat}, []WarmupFunc{ok, fail}}, wantCode: http.StatusInternalServerError},
+		{name: "panic", svc: warmService{testService{endpoints: cat}, []WarmupFunc{func(context.Context) error {
+			panic("no cats")
+		}}}, wantCode: http.StatusInternalServerError},
+		{name: "timeout", svc: warmService{testService{endpoints: cat}, []WarmupFunc{ok, hang}}, wantCode: http.StatusGatewayTimeout},
+		{name: "service endpoint", svc: warmService{testService{endpoints: map[string]map[string]HTTPEndpoint{
+			warmupURI: {"GET": HTTPEndpoint{Endpoint: func(context.Context, interface{}) (interface{}, error) {
+				return NewJSONStatusResponse("warm", http.StatusAccepted), nil
+			}}},
+		}}, []WarmupFunc{fail}}, wantCode: http.StatusAccepted},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svr := newTestServer(t, test.svc, WarmupTimeout(10*time.Millisecond))
+			w := serve(svr, http.MethodGet, warmupURI, "")
+			if w.Code != test.wantCode {
+				t.Errorf("expected status %d, got %d", test.wantCode, w.Code)
+			}
+		})
+	}
+}